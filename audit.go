@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultAuditAge is how many days old a password can be before audit
+// reports it
+const defaultAuditAge = 365
+
+// audit reports problems with the passwords and urls of the entries,
+// passwords older than days are reported as old (0 to not check)
+func (u *uiContext) audit(days int) error {
+	var oldBefore time.Time
+	if days > 0 {
+		oldBefore = time.Now().AddDate(0, 0, -days)
+	}
+
+	findings, err := u.store.Audit(oldBefore)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		infoColor.Println("no problems found")
+		return nil
+	}
+
+	width := 0
+	for _, f := range findings {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(u.out, "%s %s %s\n",
+			errColor.Sprintf("%-7s", f.Kind),
+			keyColor.Sprintf("%-*s", width, f.Name),
+			f.Reason,
+		)
+	}
+
+	entries := make(map[string]struct{})
+	for _, f := range findings {
+		entries[f.UUID] = struct{}{}
+	}
+	infoColor.Printf("%d problems in %d entries\n", len(findings), len(entries))
+	return nil
+}
+
+// auditSubcommand is audit run from the command line
+func auditSubcommand(u *uiContext) error {
+	return u.audit(flagAuditAge)
+}
@@ -0,0 +1,184 @@
+package blobformat
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FindingKind is a kind of problem found by Audit
+type FindingKind int
+
+// Kinds of findings, in the order Audit returns them
+const (
+	// FindingReused is a password that more than one entry has
+	FindingReused FindingKind = iota + 1
+	// FindingWeak is a password that is easy to guess
+	FindingWeak
+	// FindingOld is a password that hasn't been changed in a long time
+	FindingOld
+	// FindingNoTwoFactor is an entry for a site known to support totp that
+	// doesn't have it set
+	FindingNoTwoFactor
+	// FindingInsecureURL is an entry whose url is http:// so the password
+	// would be sent in the clear
+	FindingInsecureURL
+)
+
+func (f FindingKind) String() string {
+	switch f {
+	case FindingReused:
+		return "reused"
+	case FindingWeak:
+		return "weak"
+	case FindingOld:
+		return "old"
+	case FindingNoTwoFactor:
+		return "no totp"
+	case FindingInsecureURL:
+		return "http"
+	default:
+		return "unknown"
+	}
+}
+
+// Finding is a problem with an entry found by Audit
+type Finding struct {
+	Kind   FindingKind
+	UUID   string
+	Name   string
+	Reason string
+}
+
+// twoFactorDomains are sites known to support totp, subdomains of them
+// match as well
+var twoFactorDomains = []string{
+	"amazon.com", "apple.com", "atlassian.com", "aws.amazon.com",
+	"binance.com", "bitbucket.org", "cloudflare.com", "coinbase.com",
+	"digitalocean.com", "discord.com", "dropbox.com", "facebook.com",
+	"fastmail.com", "github.com", "gitlab.com", "google.com",
+	"heroku.com", "instagram.com", "kraken.com", "linkedin.com",
+	"live.com", "microsoft.com", "npmjs.com", "paypal.com", "proton.me",
+	"reddit.com", "slack.com", "stripe.com", "twitch.tv", "twitter.com",
+	"x.com", "zoom.us",
+}
+
+// Audit checks the passwords and urls of the entries for problems. Passwords
+// last changed (according to the log) before oldBefore are old, the zero
+// time turns that check off. User entries are not checked.
+func (b Blobs) Audit(oldBefore time.Time) ([]Finding, error) {
+	if err := b.UpdateSnapshot(); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	add := func(kind FindingKind, uuid string, blob Blob, reason string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Kind:   kind,
+			UUID:   uuid,
+			Name:   blob.Name(),
+			Reason: fmt.Sprintf(reason, args...),
+		})
+	}
+
+	// Entries with each password
+	byPass := make(map[string][]string)
+
+	for uuid, entry := range b.DB.Snapshot {
+		blob := Blob(entry)
+		name := blob.Name()
+		if IsUserEntry(name) {
+			continue
+		}
+
+		if pass := blob[KeyPass]; len(pass) != 0 {
+			byPass[pass] = append(byPass[pass], uuid)
+
+			if reason := weakPassword(pass, path.Base(name), blob[KeyUser]); len(reason) != 0 {
+				add(FindingWeak, uuid, blob, "password %s", reason)
+			}
+
+			if !oldBefore.IsZero() {
+				changed, err := b.lastChanged(uuid, KeyPass)
+				if err != nil {
+					return nil, err
+				}
+				if changed.Before(oldBefore) {
+					add(FindingOld, uuid, blob, "password was last changed %s", changed.Format(dateLayout))
+				}
+			}
+		}
+
+		link := blob[KeyURL]
+		if len(link) == 0 {
+			continue
+		}
+		uri, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		if uri.Scheme == "http" {
+			add(FindingInsecureURL, uuid, blob, "url %s is not https", link)
+		}
+		if domain := twoFactorDomain(uri.Hostname()); len(domain) != 0 && len(blob[KeyTwoFactor]) == 0 {
+			add(FindingNoTwoFactor, uuid, blob, "%s supports totp but it isn't set", domain)
+		}
+	}
+
+	for uuid, entry := range b.DB.Snapshot {
+		blob := Blob(entry)
+		others := byPass[blob[KeyPass]]
+		if len(others) < 2 || IsUserEntry(blob.Name()) {
+			continue
+		}
+
+		var names []string
+		for _, o := range others {
+			if o != uuid {
+				names = append(names, Blob(b.DB.Snapshot[o]).Name())
+			}
+		}
+		sort.Strings(names)
+		add(FindingReused, uuid, blob, "password is also used by %s", strings.Join(names, ", "))
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Kind != findings[j].Kind {
+			return findings[i].Kind < findings[j].Kind
+		}
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+		return findings[i].UUID < findings[j].UUID
+	})
+	return findings, nil
+}
+
+// lastChanged returns when a key of an entry was last changed
+func (b Blobs) lastChanged(uuid, key string) (time.Time, error) {
+	blames, err := b.Blame(uuid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, bl := range blames {
+		if bl.Key == key {
+			return bl.Time, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// twoFactorDomain returns the domain in twoFactorDomains the host is or is a
+// subdomain of, empty if there isn't one
+func twoFactorDomain(host string) string {
+	host = strings.ToLower(host)
+	for _, d := range twoFactorDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
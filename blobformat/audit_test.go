@@ -0,0 +1,216 @@
+package blobformat
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/bpass/txlogs"
+)
+
+// Strong passwords that don't contain any sequences or keyboard walks
+const (
+	strongPass1 = "Xk9#mQ2vLp7!"
+	strongPass2 = "Tz4$wR8nGj3@"
+	strongPass3 = "Hb6%yU1cKd5&"
+)
+
+// addEntry adds an entry with the keys and values set directly in the log,
+// skipping the checks Blobs does
+func addEntry(t *testing.T, b Blobs, name string, keyvalues ...string) string {
+	t.Helper()
+
+	uuid, err := b.DB.Add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.DB.Set(uuid, KeyName, name)
+	for i := 0; i < len(keyvalues); i += 2 {
+		b.DB.Set(uuid, keyvalues[i], keyvalues[i+1])
+	}
+	return uuid
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	b := Blobs{DB: new(txlogs.DB)}
+	add := func(name string, keyvalues ...string) string {
+		return addEntry(t, b, name, keyvalues...)
+	}
+
+	// Two entries can end up with the same name after a merge
+	reused1 := add("shared", KeyPass, strongPass1, KeyURL, "https://example.com")
+	reused2 := add("shared", KeyPass, strongPass1)
+	weak := add("weak", KeyPass, "Password123!")
+	insecure := add("insecure", KeyPass, strongPass2, KeyURL, "http://example.org")
+	noTOTP := add("github", KeyPass, strongPass3, KeyURL, "https://api.github.com/login")
+	add("gitlab", KeyPass, strongPass2+"x", KeyURL, "https://gitlab.com", KeyTwoFactor, "otpauth://totp/x?secret=ABC")
+	add("user/bob", KeyPass, strongPass1)
+
+	findings, err := b.Audit(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		Kind   FindingKind
+		UUID   string
+		Reason string
+	}{
+		{FindingReused, reused1, "password is also used by shared"},
+		{FindingReused, reused2, "password is also used by shared"},
+		{FindingWeak, weak, "password is a common password"},
+		{FindingNoTwoFactor, noTOTP, "github.com supports totp but it isn't set"},
+		{FindingInsecureURL, insecure, "url http://example.org is not https"},
+	}
+	if reused2 < reused1 {
+		want[0].UUID, want[1].UUID = reused2, reused1
+	}
+
+	if len(findings) != len(want) {
+		t.Fatalf("wrong number of findings: %#v", findings)
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.Kind != w.Kind || f.UUID != w.UUID || f.Reason != w.Reason {
+			t.Errorf("%d) finding was wrong: %#v", i, f)
+		}
+	}
+
+	// Everything was just set so it's all old if the cutoff is in the future
+	findings, err = b.Audit(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := 0
+	for _, f := range findings {
+		if f.Kind != FindingOld {
+			continue
+		}
+		old++
+		if !strings.HasPrefix(f.Reason, "password was last changed ") {
+			t.Errorf("reason was wrong: %q", f.Reason)
+		}
+	}
+	if old != 6 {
+		t.Errorf("expected the 6 entries with passwords (not the user) to be old: %d", old)
+	}
+
+	findings, err = b.Audit(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		if f.Kind == FindingOld {
+			t.Errorf("nothing should be old: %#v", f)
+		}
+	}
+}
+
+func TestWeakPassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Pass    string
+		Related []string
+		Want    string
+	}{
+		{Pass: "password", Want: "is a common password"},
+		{Pass: "Password123!", Want: "is a common password"},
+		{Pass: "letmein2024", Want: "is a common password"},
+		{Pass: "qwertyuiop", Want: "is easy to guess (about 13 bits of entropy)"},
+		{Pass: "aaaaaaaaaaaaaaaaaaaa", Want: "is easy to guess (about 23 bits of entropy)"},
+		{Pass: "Github" + strongPass1, Related: []string{"github"}, Want: `contains "github"`},
+		{Pass: "Bob" + strongPass1, Related: []string{"site", "bob"}, Want: `contains "bob"`},
+		{Pass: "ab" + strongPass1, Related: []string{"ab"}},
+		{Pass: strongPass1},
+	}
+
+	for _, test := range tests {
+		if got := weakPassword(test.Pass, test.Related...); got != test.Want {
+			t.Errorf("%s: want %q, got %q", test.Pass, test.Want, got)
+		}
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	t.Parallel()
+
+	lower := math.Log2(26)
+	tests := []struct {
+		Pass string
+		Want float64
+	}{
+		{"", 0},
+		{"x", lower},
+		// Sequences, repeats and keyboard walks are a bit a character
+		{"abcdef", lower + 5},
+		{"fedcba", lower + 5},
+		{"zzzzzz", lower + 5},
+		{"asdfgh", lower + 5},
+		{"poiuyt", lower + 5},
+		{"q1", math.Log2(36) * 2},
+		{"aA", math.Log2(52) + 1},
+		{"a!", math.Log2(59) * 2},
+	}
+
+	for _, test := range tests {
+		if got := PasswordEntropy(test.Pass); math.Abs(got-test.Want) > 0.0001 {
+			t.Errorf("%q: want %f, got %f", test.Pass, test.Want, got)
+		}
+	}
+}
+
+func TestPredictable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Prev, C rune
+		Want    bool
+	}{
+		{'a', 'a', true},
+		{'a', 'b', true},
+		{'b', 'a', true},
+		{'q', 'w', true},
+		{'w', 'q', true},
+		{'p', '[', true},
+		{'l', ';', true},
+		{'0', '-', true},
+		{'a', 'q', false},
+		{'1', '3', false},
+		{'m', ',', true},
+		{'m', 'q', false},
+	}
+
+	for _, test := range tests {
+		if got := predictable(test.Prev, test.C); got != test.Want {
+			t.Errorf("%q then %q: want %t", test.Prev, test.C, test.Want)
+		}
+	}
+}
+
+func TestTwoFactorDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Host string
+		Want string
+	}{
+		{"github.com", "github.com"},
+		{"api.github.com", "github.com"},
+		{"GitHub.com", "github.com"},
+		{"aws.amazon.com", "amazon.com"},
+		{"notgithub.com", ""},
+		{"github.com.example.net", ""},
+		{"example.com", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := twoFactorDomain(test.Host); got != test.Want {
+			t.Errorf("%q: want %q, got %q", test.Host, test.Want, got)
+		}
+	}
+}
@@ -15,9 +15,13 @@ import (
 // Blob is a context of a single blob
 type Blob txlogs.Entry
 
-// Keys returns all the keys known about
+// Keys returns all the keys known about, the hidden keys that hold list
+// items are not included.
 func (b Blob) Keys() (keys []string) {
 	for k := range b {
+		if txlogs.IsItemKey(k) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	return keys
@@ -86,6 +90,16 @@ func (b Blob) Labels() []string {
 	return strings.Split(labelVal, ",")
 }
 
+// Secrets returns the keys that have been marked secret in this entry
+func (b Blob) Secrets() []string {
+	secretsVal := b[KeySecrets]
+	if len(secretsVal) == 0 {
+		return nil
+	}
+
+	return strings.Split(secretsVal, ",")
+}
+
 // Updated timestamp, if not set it will be time's zero value, returns an error
 // if the underlying type was wrong.
 func (b Blob) Updated() (time.Time, error) {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,10 +15,19 @@ import (
 	"github.com/pquerna/otp"
 )
 
+func init() {
+	// Every change sets updated, concurrent changes to an entry would
+	// always conflict on it otherwise
+	txlogs.LatestKeys[KeyUpdated] = struct{}{}
+}
+
 // Sentinel errors
 var (
 	ErrNameNotUnique = errors.New("name is not unique")
 	ErrKeyNotAllowed = errors.New("key is not allowed")
+	ErrNoSuchLabel   = errors.New("label not found")
+	ErrNotSecret     = errors.New("key is not marked secret")
+	ErrNoName        = errors.New("snapshot is from before the entry had a name")
 )
 
 type keyNotAllowed string
@@ -129,6 +139,23 @@ AllKeys:
 	return entries, nil
 }
 
+// Unnamed returns the uuids of entries that have no name, they can't be
+// found by name or search.
+func (b Blobs) Unnamed() ([]string, error) {
+	if err := b.UpdateSnapshot(); err != nil {
+		return nil, err
+	}
+
+	var uuids []string
+	for uuid, entry := range b.DB.Snapshot {
+		if len(Blob(entry).Name()) == 0 {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+	return uuids, nil
+}
+
 // SearchLabels searches by finding all entries with all the labels given.
 func (b Blobs) SearchLabels(labels ...string) (entries SearchResults, err error) {
 	if err := b.UpdateSnapshot(); err != nil {
@@ -356,19 +383,59 @@ func (b Blobs) Rename(uuid, newName string) error {
 // Set the key in name to value, properly updates 'updated' and 'snapshots'.
 // returns keyNotAllowed error if a protected key is attempted to be set.
 // To update protected keys like: labels, notes, twofactor, updated you must
-// use the specific setters.
+// use the specific setters. Values that aren't valid for the entry's type
+// return an error that IsInvalidValue is true for.
 func (b Blobs) Set(uuid, key, value string) error {
 	for _, p := range protectedKeys {
 		if strings.EqualFold(key, p) {
 			return keyNotAllowed(key)
 		}
 	}
+	if err := b.validate(uuid, key, value); err != nil {
+		return err
+	}
 
 	b.touchUpdated(uuid)
 	b.DB.Set(uuid, key, value)
 	return nil
 }
 
+// validate checks a value is valid for the type of the entry, and that the
+// type is a known one if that's what's being set
+func (b Blobs) validate(uuid, key, value string) error {
+	if key == KeyType {
+		if _, ok := FindSchema(value); !ok {
+			return invalidValue{key: key, err: fmt.Errorf("unknown type, use one of: %s", strings.Join(Types(), ", "))}
+		}
+		return nil
+	}
+	if len(value) == 0 {
+		return nil
+	}
+
+	// Any type of entry can have these
+	switch key {
+	case KeyExpires:
+		return Field{Key: key, Validate: validateDate}.check(value)
+	case KeyRotateEvery:
+		return Field{Key: key, Validate: validateDays}.check(value)
+	}
+
+	blob, err := b.Find(uuid)
+	if err != nil || blob == nil {
+		return err
+	}
+	schema, ok := blob.Schema()
+	if !ok {
+		return nil
+	}
+	f, ok := schema.Field(key)
+	if !ok {
+		return nil
+	}
+	return f.check(value)
+}
+
 // DeleteKey from an entry, follows the rules of Set() for protected keys.
 func (b Blobs) DeleteKey(uuid, key string) error {
 	switch key {
@@ -413,51 +480,177 @@ func (b Blobs) SetTwofactor(uuid, uriOrKey string) error {
 	return nil
 }
 
-// AddLabel to entry.
+// AddLabel to entry. Labels are a list so labels added by different copies
+// of the file are all kept when they're merged.
 func (b Blobs) AddLabel(uuid, label string) (err error) {
 	entry, err := b.MustFind(uuid)
 	if err != nil {
 		return err
 	}
 
-	labelVal := entry[KeyLabels]
-	if len(labelVal) == 0 {
-		labelVal = label
-	} else {
-		labels := strings.Split(labelVal, ",")
-		labels = append(labels, label)
-		labelVal = strings.Join(labels, ",")
+	for _, l := range entry.Labels() {
+		if l == label {
+			return nil
+		}
 	}
 
 	b.touchUpdated(uuid)
-	return b.Set(uuid, KeyLabels, labelVal)
+	b.DB.AddItem(uuid, KeyLabels, label)
+	return nil
 }
 
-// RemoveLabel from uuid using the list element's index
-func (b Blobs) RemoveLabel(uuid string, index int) (err error) {
+// RemoveLabel from entry. Every item with the label is removed since it may
+// have been added by more than one copy of the file.
+func (b Blobs) RemoveLabel(uuid, label string) (err error) {
 	entry, err := b.MustFind(uuid)
 	if err != nil {
 		return err
 	}
 
-	labels := strings.Split(entry[KeyLabels], ",")
-	if index >= len(labels) {
-		return errors.New("index out of range")
+	var ids []string
+	for _, item := range txlogs.Entry(entry).Items(KeyLabels) {
+		if item.Value == label {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return ErrNoSuchLabel
 	}
 
-	if len(labels) == 1 {
-		b.DB.DeleteKey(uuid, KeyLabels)
-		return nil
+	b.touchUpdated(uuid)
+	for _, id := range ids {
+		b.DB.DeleteItem(uuid, KeyLabels, id)
+	}
+	return nil
+}
+
+// MarkSecret marks a key in the entry as secret so its value is masked like
+// a password's. The marks are a list like labels so they survive merges.
+func (b Blobs) MarkSecret(uuid, key string) (err error) {
+	entry, err := b.MustFind(uuid)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range entry.Secrets() {
+		if s == key {
+			return nil
+		}
+	}
+
+	b.touchUpdated(uuid)
+	b.DB.AddItem(uuid, KeySecrets, key)
+	return nil
+}
+
+// UnmarkSecret removes the secret mark from a key in the entry, returns
+// ErrNotSecret if it wasn't marked. Keys that are always secret can't be
+// unmarked.
+func (b Blobs) UnmarkSecret(uuid, key string) (err error) {
+	entry, err := b.MustFind(uuid)
+	if err != nil {
+		return err
 	}
 
-	copy(labels[index:], labels[index+1:])
-	labels = labels[len(labels)-1:]
+	var ids []string
+	for _, item := range txlogs.Entry(entry).Items(KeySecrets) {
+		if item.Value == key {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return ErrNotSecret
+	}
 
 	b.touchUpdated(uuid)
-	b.DB.Set(uuid, KeyLabels, strings.Join(labels, ","))
+	for _, id := range ids {
+		b.DB.DeleteItem(uuid, KeySecrets, id)
+	}
 	return nil
 }
 
+// Revert an entry to how it was snapshot versions ago (see show). It's done
+// in a single transaction so it can be undone in one go. The updated key is
+// refreshed rather than reverted. Returns ErrNameNotUnique if another entry
+// has since taken the name it had and ErrNoName if the entry had no name
+// yet, the first few snapshots of an entry are from while it was being made.
+func (b Blobs) Revert(uuid string, snapshot int) (changes int, err error) {
+	current, err := b.MustFind(uuid)
+	if err != nil {
+		return 0, err
+	}
+
+	want, err := b.DB.EntrySnapshotAt(uuid, snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	name, ok := want[KeyName]
+	if !ok {
+		return 0, ErrNoName
+	}
+	for otherUUID, entry := range b.DB.Snapshot {
+		if otherUUID != uuid && entry[KeyName] == name {
+			return 0, ErrNameNotUnique
+		}
+	}
+
+	if updated, ok := current[KeyUpdated]; ok {
+		want[KeyUpdated] = updated
+	} else {
+		delete(want, KeyUpdated)
+	}
+
+	err = b.DB.Do(func() error {
+		changes, err = b.DB.Reconcile(uuid, want)
+		if err == nil && changes != 0 {
+			b.touchUpdated(uuid)
+		}
+		return err
+	})
+	return changes, err
+}
+
+// Trash returns the entries that were deleted in the order they were deleted
+func (b Blobs) Trash() ([]txlogs.Deleted, error) {
+	return b.DB.Deleted()
+}
+
+// Undelete re-creates a deleted entry (see Trash) and returns its new uuid.
+// Returns ErrNameNotUnique if another entry has since taken its name.
+func (b Blobs) Undelete(uuid string) (newUUID string, err error) {
+	if err = b.UpdateSnapshot(); err != nil {
+		return "", err
+	}
+
+	deleted, err := b.DB.Deleted()
+	if err != nil {
+		return "", err
+	}
+
+	for _, d := range deleted {
+		if d.UUID != uuid {
+			continue
+		}
+
+		name := Blob(d.Entry).Name()
+		for _, entry := range b.DB.Snapshot {
+			if Blob(entry).Name() == name {
+				return "", ErrNameNotUnique
+			}
+		}
+	}
+
+	err = b.DB.Do(func() error {
+		newUUID, err = b.DB.Undelete(uuid)
+		if err == nil {
+			b.touchUpdated(newUUID)
+		}
+		return err
+	})
+	return newUUID, err
+}
+
 // NewSync creates a new blob with a unique name to have values set on it before
 // calling Add() to add it to the store.
 //
@@ -491,6 +684,17 @@ func IsUserEntry(name string) bool {
 	return strings.HasPrefix(name, userPrefix)
 }
 
+// IsSecretKey checks to see if the key holds a value that should be masked
+func IsSecretKey(key string) bool {
+	for _, k := range secretKeys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // SplitUsername returns a username from an entry name, returns empty string
 // if this was not a proper user entryname
 func SplitUsername(entryname string) string {
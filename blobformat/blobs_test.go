@@ -0,0 +1,114 @@
+package blobformat
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aarondl/bpass/txlogs"
+)
+
+func TestRevert(t *testing.T) {
+	t.Parallel()
+
+	b := Blobs{DB: new(txlogs.DB)}
+	if _, err := b.New("gitlab"); err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := b.New("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Set(uuid, KeyUser, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest snapshots are from before the name was set
+	oldest := b.NVersions(uuid)
+	if _, err = b.Revert(uuid, oldest); err == nil {
+		t.Error("reverting to before it existed should fail")
+	}
+	if _, err = b.Revert(uuid, oldest-1); err != ErrNoName {
+		t.Error("reverting to before it had a name should fail:", err)
+	}
+
+	// Back to before the user was set
+	for n := 1; n <= oldest; n++ {
+		want, err := b.DB.EntrySnapshotAt(uuid, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := want[KeyUser]; ok {
+			continue
+		}
+		if _, ok := want[KeyName]; !ok {
+			t.Fatal("there should be a snapshot with a name and no user")
+		}
+
+		if _, err = b.Revert(uuid, n); err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+
+	blob, err := b.MustFind(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blob[KeyUser]; ok || blob.Name() != "github" {
+		t.Errorf("revert was wrong: %v", blob)
+	}
+}
+
+func TestMergeConcurrentEdits(t *testing.T) {
+	t.Parallel()
+
+	b := Blobs{DB: new(txlogs.DB)}
+	uuid, err := b.New("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := Blobs{DB: &txlogs.DB{Log: append([]txlogs.Tx(nil), b.DB.Log...)}}
+	if err = b.Set(uuid, KeyUser, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.Set(uuid, KeyEmail, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.AddLabel(uuid, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both sides set updated, it's not a conflict
+	merged, conflicts := txlogs.Merge(b.DB.Log, other.DB.Log, nil)
+	if len(conflicts) != 0 {
+		t.Fatalf("there should be no conflicts: %#v", conflicts)
+	}
+
+	m := Blobs{DB: &txlogs.DB{Log: merged}}
+	blob, err := m.MustFind(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob[KeyUser] != "bob" || blob[KeyEmail] != "bob@example.com" {
+		t.Errorf("both edits should be kept: %v", blob)
+	}
+
+	var latest int64
+	for _, side := range []Blobs{b, other} {
+		blob, err := side.MustFind(uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := strconv.ParseInt(blob[KeyUpdated], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated > latest {
+			latest = updated
+		}
+	}
+	if blob[KeyUpdated] != strconv.FormatInt(latest, 10) {
+		t.Errorf("updated should be the later one: %s", blob[KeyUpdated])
+	}
+}
@@ -6,6 +6,8 @@ const (
 	// System level keys (things that allow the system to work)
 	KeyName    = "name"
 	KeyUpdated = "updated"
+	KeyType    = "type"
+	KeySecrets = "secrets"
 
 	// User level known keys
 	KeyUser      = "user"
@@ -16,6 +18,22 @@ const (
 	KeyNotes     = "notes"
 	KeyLabels    = "labels"
 
+	// Reminders to change the password, see Blobs.Due
+	KeyExpires     = "expires"
+	KeyRotateEvery = "rotate_every"
+
+	// Keys of the built in types, see Schemas
+	KeyCardholder = "cardholder"
+	KeyNumber     = "number"
+	KeyExpiry     = "expiry"
+	KeyCVV        = "cvv"
+	KeyPIN        = "pin"
+	KeyPassphrase = "passphrase"
+	KeyFullName   = "fullname"
+	KeyPhone      = "phone"
+	KeyAddress    = "address"
+	KeyBirthday   = "birthday"
+
 	// Synchronization keys in user data
 	KeySync       = "sync"
 	KeyPriv       = "privkey"
@@ -38,6 +56,8 @@ var (
 	knownKeys = []string{
 		KeyName,
 		KeyUpdated,
+		KeyType,
+		KeySecrets,
 
 		KeyUser,
 		KeyEmail,
@@ -45,6 +65,8 @@ var (
 		KeyTwoFactor,
 		KeyNotes,
 		KeyLabels,
+		KeyExpires,
+		KeyRotateEvery,
 
 		KeySync,
 		KeyPriv,
@@ -52,10 +74,22 @@ var (
 		KeyKnownHosts,
 	}
 
+	// secretKeys is a list of keys whose values should not be shown unless
+	// asked for
+	secretKeys = []string{
+		KeyPass,
+		KeyTwoFactor,
+		KeyPriv,
+		KeyIV,
+		KeySalt,
+		KeyMKey,
+	}
+
 	// protectedKeys is a list of keys that cannot be set to a string value
 	protectedKeys = []string{
 		// Special setters
 		KeyTwoFactor,
+		KeySecrets,
 
 		// Forbidden
 		KeyName,
@@ -0,0 +1,136 @@
+package blobformat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// dateLayout is the format of dates like expires
+const dateLayout = "2006-01-02"
+
+// Due is an entry whose password has expired or should be rotated, or soon
+// will be
+type Due struct {
+	UUID string
+	Name string
+	// Reason is the key that made it due, KeyExpires or KeyRotateEvery
+	Reason string
+	// When it's due, in the past if it's overdue
+	When time.Time
+	// Err is why it can't be worked out when the entry is due, a bad value
+	// for one of the keys. Reason and When are not set.
+	Err error
+}
+
+// Expires returns when the entry expires, which is the end of the day (in
+// local time) of the date set. If not set it will be time's zero value.
+func (b Blob) Expires() (time.Time, error) {
+	expires, ok := b[KeyExpires]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, expires, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", KeyExpires, err)
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// RotateEvery returns how often the entry's password should be changed, 0
+// if it's not set
+func (b Blob) RotateEvery() (time.Duration, error) {
+	every, ok := b[KeyRotateEvery]
+	if !ok {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(every)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", KeyRotateEvery, err)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// Due returns the entries that are due before a time, soonest first. An
+// entry is due when it expires or rotate_every days after its secrets (the
+// password for a login) were last changed, which is found in the log. If
+// both are set the sooner one is used.
+//
+// Entries with bad values for those keys are returned first with Err set so
+// they can be reported without hiding the others.
+func (b Blobs) Due(before time.Time) ([]Due, error) {
+	if err := b.UpdateSnapshot(); err != nil {
+		return nil, err
+	}
+
+	var due []Due
+	for uuid, entry := range b.DB.Snapshot {
+		blob := Blob(entry)
+
+		d, ok, err := b.due(uuid, blob)
+		if err != nil {
+			due = append(due, Due{
+				UUID: uuid,
+				Name: blob.Name(),
+				Err:  fmt.Errorf("entry %s: %w", blob.Name(), err),
+			})
+			continue
+		}
+		if ok && d.When.Before(before) {
+			due = append(due, d)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if (due[i].Err != nil) != (due[j].Err != nil) {
+			return due[i].Err != nil
+		}
+		if due[i].Err != nil {
+			return due[i].Name < due[j].Name
+		}
+		return due[i].When.Before(due[j].When)
+	})
+	return due, nil
+}
+
+// due works out when an entry is due, false if it never is
+func (b Blobs) due(uuid string, blob Blob) (d Due, ok bool, err error) {
+	d = Due{UUID: uuid, Name: blob.Name()}
+
+	expires, err := blob.Expires()
+	if err != nil {
+		return d, false, err
+	}
+	if !expires.IsZero() {
+		d.Reason, d.When, ok = KeyExpires, expires, true
+	}
+
+	every, err := blob.RotateEvery()
+	if err != nil || every == 0 {
+		return d, ok, err
+	}
+
+	blames, err := b.Blame(uuid)
+	if err != nil {
+		return d, false, err
+	}
+	// The totp secret isn't something that's rotated
+	var changed time.Time
+	for _, bl := range blames {
+		if bl.Key != KeyTwoFactor && blob.IsSecret(bl.Key) && bl.Time.After(changed) {
+			changed = bl.Time
+		}
+	}
+	if changed.IsZero() {
+		// Nothing to rotate
+		return d, ok, nil
+	}
+
+	if rotate := changed.Add(every); !ok || rotate.Before(d.When) {
+		d.Reason, d.When, ok = KeyRotateEvery, rotate, true
+	}
+	return d, ok, nil
+}
@@ -0,0 +1,178 @@
+package blobformat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/bpass/txlogs"
+)
+
+// backdate makes it look like a key of an entry was set ago in the past
+func backdate(b Blobs, uuid, key string, ago time.Duration) {
+	when := time.Now().Add(-ago).UnixNano()
+	for i, tx := range b.DB.Log {
+		if tx.UUID == uuid && tx.Key == key {
+			b.DB.Log[i].Time = when
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	t.Parallel()
+
+	const day = 24 * time.Hour
+	now := time.Now()
+	date := func(days int) string {
+		return now.AddDate(0, 0, days).Format(dateLayout)
+	}
+
+	b := Blobs{DB: new(txlogs.DB)}
+	add := func(name string, keyvalues ...string) string {
+		return addEntry(t, b, name, keyvalues...)
+	}
+
+	expires := add("expires", KeyExpires, date(3))
+	today := add("today", KeyExpires, date(0))
+	add("far", KeyExpires, date(60))
+
+	rotate := add("rotate", KeyPass, "a", KeyRotateEvery, "90")
+	backdate(b, rotate, KeyPass, 100*day)
+
+	expiresSooner := add("expires sooner", KeyPass, "a", KeyRotateEvery, "30", KeyExpires, date(5))
+	backdate(b, expiresSooner, KeyPass, 10*day)
+	rotateSooner := add("rotate sooner", KeyPass, "a", KeyRotateEvery, "30", KeyExpires, date(25))
+	backdate(b, rotateSooner, KeyPass, 28*day)
+
+	// Changing the totp secret doesn't count as rotating the password
+	totp := add("totp", KeyPass, "a", KeyRotateEvery, "30", KeyTwoFactor, "b")
+	backdate(b, totp, KeyPass, 100*day)
+	backdate(b, totp, KeyTwoFactor, day)
+
+	add("no secrets", KeyURL, "https://example.com", KeyRotateEvery, "30")
+	onlyTOTP := add("only totp", KeyTwoFactor, "b", KeyRotateEvery, "30")
+	backdate(b, onlyTOTP, KeyTwoFactor, 100*day)
+
+	due, err := b.Due(now.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		UUID   string
+		Reason string
+	}{
+		{totp, KeyRotateEvery},
+		{rotate, KeyRotateEvery},
+		{today, KeyExpires},
+		{rotateSooner, KeyRotateEvery},
+		{expires, KeyExpires},
+		{expiresSooner, KeyExpires},
+	}
+	if len(due) != len(want) {
+		t.Fatalf("wrong number due: %#v", due)
+	}
+	for i, w := range want {
+		if due[i].UUID != w.UUID || due[i].Reason != w.Reason {
+			t.Errorf("%d) due was wrong: %#v", i, due[i])
+		}
+	}
+
+	if d := due[0]; d.Name != "totp" || d.When.Format(dateLayout) != date(-70) {
+		t.Errorf("totp should be due 30 days after the password was set: %#v", d)
+	}
+	if d := due[4]; d.When.Format(dateLayout) != date(3) || d.When.Hour() != 23 {
+		t.Errorf("expires should be the end of the day: %v", d.When)
+	}
+
+	// Expiring today isn't overdue until the day is over
+	due, err = b.Due(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].UUID != totp || due[1].UUID != rotate {
+		t.Errorf("overdue was wrong: %#v", due)
+	}
+}
+
+func TestDueBadValues(t *testing.T) {
+	t.Parallel()
+
+	for _, keyvalues := range [][]string{
+		{KeyExpires, "soon"},
+		{KeyPass, "a", KeyRotateEvery, "monthly"},
+	} {
+		b := Blobs{DB: new(txlogs.DB)}
+		overdue := addEntry(t, b, "overdue", KeyExpires, "2000-01-01")
+		bad := addEntry(t, b, "bad", keyvalues...)
+
+		// The bad entry doesn't stop the others from being checked
+		due, err := b.Due(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 2 || due[0].UUID != bad || due[1].UUID != overdue || due[1].Err != nil {
+			t.Fatalf("%v: due was wrong: %#v", keyvalues, due)
+		}
+		if err = due[0].Err; err == nil {
+			t.Errorf("%v: expected an error", keyvalues)
+		} else if !strings.Contains(err.Error(), "entry bad") {
+			t.Errorf("%v: error should name the entry: %v", keyvalues, err)
+		}
+	}
+}
+
+func TestExpires(t *testing.T) {
+	t.Parallel()
+
+	when, err := Blob{}.Expires()
+	if err != nil || !when.IsZero() {
+		t.Error("not set should be the zero time:", when, err)
+	}
+
+	when, err = Blob{KeyExpires: "2026-10-16"}.Expires()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	if !when.Before(want) || want.Sub(when) > time.Second {
+		t.Error("should be the end of the day in local time:", when)
+	}
+
+	if _, err = (Blob{KeyExpires: "16/10/2026"}).Expires(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestRotateEvery(t *testing.T) {
+	t.Parallel()
+
+	every, err := Blob{}.RotateEvery()
+	if err != nil || every != 0 {
+		t.Error("not set should be 0:", every, err)
+	}
+
+	every, err = Blob{KeyRotateEvery: "30"}.RotateEvery()
+	if err != nil || every != 30*24*time.Hour {
+		t.Error("should be 30 days:", every, err)
+	}
+
+	if _, err = (Blob{KeyRotateEvery: "a month"}).RotateEvery(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestValidateDays(t *testing.T) {
+	t.Parallel()
+
+	for _, good := range []string{"1", "30", "365"} {
+		if err := validateDays(good); err != nil {
+			t.Errorf("%q: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "0", "-5", "1.5", "ten"} {
+		if err := validateDays(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package blobformat
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Types of entries
+const (
+	TypeLogin    = "login"
+	TypeNote     = "note"
+	TypeCard     = "card"
+	TypeSSHKey   = "sshkey"
+	TypeIdentity = "identity"
+)
+
+// Field is a key an entry of a type has
+type Field struct {
+	Key string
+	// Required fields must not be empty
+	Required bool
+	// Secret fields are not shown unless asked for
+	Secret bool
+	// Multiline fields are entered and shown as more than one line
+	Multiline bool
+	// Validate returns an error if the value is not valid for the field, it's
+	// not called for empty values. Nil means any value is fine.
+	Validate func(value string) error
+}
+
+// Schema is a type of entry, the fields are in the order they're shown
+type Schema struct {
+	Type   string
+	Fields []Field
+}
+
+// Schemas are the built in types of entry. Entries without a type are from
+// before types existed and are logins.
+var Schemas = []Schema{
+	{
+		Type: TypeLogin,
+		Fields: []Field{
+			{Key: KeyUser},
+			{Key: KeyEmail, Validate: validateEmail},
+			{Key: KeyPass, Secret: true},
+			{Key: KeyURL, Validate: validateURL},
+			{Key: KeyTwoFactor, Secret: true},
+			{Key: KeyLabels},
+			{Key: KeyNotes, Multiline: true},
+		},
+	},
+	{
+		Type: TypeNote,
+		Fields: []Field{
+			{Key: KeyNotes, Required: true, Multiline: true},
+			{Key: KeyLabels},
+		},
+	},
+	{
+		Type: TypeCard,
+		Fields: []Field{
+			{Key: KeyCardholder, Required: true},
+			{Key: KeyNumber, Required: true, Secret: true, Validate: validateCardNumber},
+			{Key: KeyExpiry, Required: true, Validate: validateExpiry},
+			{Key: KeyCVV, Secret: true, Validate: validateDigits(3, 4)},
+			{Key: KeyPIN, Secret: true, Validate: validateDigits(4, 12)},
+			{Key: KeyLabels},
+			{Key: KeyNotes, Multiline: true},
+		},
+	},
+	{
+		Type: TypeSSHKey,
+		Fields: []Field{
+			{Key: KeyPriv, Required: true, Secret: true, Multiline: true, Validate: validatePrivateKey},
+			{Key: KeyPub, Validate: validatePublicKey},
+			{Key: KeyPassphrase, Secret: true},
+			{Key: KeyLabels},
+			{Key: KeyNotes, Multiline: true},
+		},
+	},
+	{
+		Type: TypeIdentity,
+		Fields: []Field{
+			{Key: KeyFullName, Required: true},
+			{Key: KeyEmail, Validate: validateEmail},
+			{Key: KeyPhone},
+			{Key: KeyAddress, Multiline: true},
+			{Key: KeyBirthday, Validate: validateDate},
+			{Key: KeyLabels},
+			{Key: KeyNotes, Multiline: true},
+		},
+	},
+}
+
+// Types returns the names of the built in types
+func Types() []string {
+	types := make([]string, len(Schemas))
+	for i, s := range Schemas {
+		types[i] = s.Type
+	}
+	return types
+}
+
+// FindSchema returns the schema of a type, false if there is none
+func FindSchema(typ string) (Schema, bool) {
+	for _, s := range Schemas {
+		if s.Type == typ {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+// Field returns the field with the key, false if the type doesn't have it
+func (s Schema) Field(key string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Validate returns an error for each required field that's missing and each
+// value that isn't valid
+func (s Schema) Validate(b Blob) []error {
+	var errs []error
+	for _, f := range s.Fields {
+		value := b[f.Key]
+		if len(value) == 0 {
+			if f.Required {
+				errs = append(errs, fmt.Errorf("%s is required", f.Key))
+			}
+			continue
+		}
+		if err := f.check(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// check that a non-empty value is valid for the field
+func (f Field) check(value string) error {
+	if f.Validate == nil {
+		return nil
+	}
+	if err := f.Validate(value); err != nil {
+		return invalidValue{key: f.Key, err: err}
+	}
+	return nil
+}
+
+// Type returns the type of the entry, entries from before types existed
+// are logins
+func (b Blob) Type() string {
+	if typ := b[KeyType]; len(typ) != 0 {
+		return typ
+	}
+	return TypeLogin
+}
+
+// Schema returns the schema for the entry's type, false if the type is not
+// one of the built in ones
+func (b Blob) Schema() (Schema, bool) {
+	return FindSchema(b.Type())
+}
+
+// IsSecret checks to see if the key holds a value that should be masked,
+// either everywhere (see IsSecretKey), in this entry's type or because it
+// was marked secret in this entry (see Blobs.MarkSecret)
+func (b Blob) IsSecret(key string) bool {
+	if IsSecretKey(key) {
+		return true
+	}
+	for _, s := range b.Secrets() {
+		if s == key {
+			return true
+		}
+	}
+
+	schema, ok := b.Schema()
+	if !ok {
+		return false
+	}
+	f, ok := schema.Field(key)
+	return ok && f.Secret
+}
+
+// invalidValue is returned when a value isn't valid for the entry's type
+type invalidValue struct {
+	key string
+	err error
+}
+
+func (i invalidValue) Error() string {
+	return fmt.Sprintf("%s is not valid: %v", i.key, i.err)
+}
+
+func (i invalidValue) Unwrap() error {
+	return i.err
+}
+
+// IsInvalidValue checks if the error is because a value is not valid for
+// the entry's type
+func IsInvalidValue(err error) bool {
+	var i invalidValue
+	return errors.As(err, &i)
+}
+
+func validateEmail(value string) error {
+	_, err := mail.ParseAddress(value)
+	return err
+}
+
+func validateURL(value string) error {
+	uri, err := url.Parse(value)
+	if err != nil {
+		return errors.New("not a valid url")
+	}
+	if uri.Scheme == "" || uri.Opaque != "" {
+		return errors.New("url must include a scheme like https://")
+	}
+	return nil
+}
+
+// validateCardNumber checks the length and the luhn checksum, spaces and
+// dashes between the digits are allowed
+func validateCardNumber(value string) error {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(value)
+	if len(digits) < 12 || len(digits) > 19 {
+		return errors.New("must be 12 to 19 digits")
+	}
+
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		c := digits[len(digits)-1-i]
+		if c < '0' || c > '9' {
+			return errors.New("must only be digits")
+		}
+
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	if sum%10 != 0 {
+		return errors.New("checksum is wrong, check for typos")
+	}
+	return nil
+}
+
+// validateExpiry accepts MM/YY and MM/YYYY
+func validateExpiry(value string) error {
+	if _, err := time.Parse("01/06", value); err == nil {
+		return nil
+	}
+	if _, err := time.Parse("01/2006", value); err == nil {
+		return nil
+	}
+	return errors.New("must be MM/YY")
+}
+
+func validateDigits(min, max int) func(string) error {
+	return func(value string) error {
+		if len(value) < min || len(value) > max {
+			return fmt.Errorf("must be %d to %d digits", min, max)
+		}
+		for _, c := range value {
+			if c < '0' || c > '9' {
+				return errors.New("must only be digits")
+			}
+		}
+		return nil
+	}
+}
+
+// validatePrivateKey checks that the key parses, a key protected by a
+// passphrase can't be parsed without it so that's all that's checked
+func validatePrivateKey(value string) error {
+	_, err := ssh.ParsePrivateKey([]byte(value))
+	var missing *ssh.PassphraseMissingError
+	if err == nil || errors.As(err, &missing) {
+		return nil
+	}
+	return err
+}
+
+func validatePublicKey(value string) error {
+	_, _, _, _, err := ssh.ParseAuthorizedKey([]byte(value))
+	return err
+}
+
+func validateDate(value string) error {
+	if _, err := time.Parse(dateLayout, value); err != nil {
+		return errors.New("must be YYYY-MM-DD")
+	}
+	return nil
+}
+
+func validateDays(value string) error {
+	if days, err := strconv.Atoi(value); err != nil || days < 1 {
+		return errors.New("must be a number of days")
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package blobformat
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestValidateCardNumber(t *testing.T) {
+	t.Parallel()
+
+	for _, good := range []string{
+		"4111111111111111",
+		"4111 1111 1111 1111",
+		"4111-1111-1111-1111",
+		"5555555555554444",
+		"378282246310005",
+	} {
+		if err := validateCardNumber(good); err != nil {
+			t.Errorf("%q: %v", good, err)
+		}
+	}
+
+	for _, bad := range []string{
+		"",
+		"4111111111111112",
+		"4111 1111 1111 1121",
+		"41111111111",
+		"41111111111111111111",
+		"4111a11111111111",
+	} {
+		if err := validateCardNumber(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestValidateExpiry(t *testing.T) {
+	t.Parallel()
+
+	for _, good := range []string{"10/26", "01/2030", "12/99"} {
+		if err := validateExpiry(good); err != nil {
+			t.Errorf("%q: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "13/26", "00/26", "1026", "2026-10", "10/6"} {
+		if err := validateExpiry(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestValidateDigits(t *testing.T) {
+	t.Parallel()
+
+	validate := validateDigits(3, 4)
+	for _, good := range []string{"123", "0000", "9876"} {
+		if err := validate(good); err != nil {
+			t.Errorf("%q: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "12", "12345", "12a", "1 23", "-123"} {
+		if err := validate(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// Can't be parsed without the passphrase, only the headers are looked at
+	encryptedPEM := pem.EncodeToMemory(&pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00000000000000000000000000000000"},
+		Bytes:   []byte("not really encrypted"),
+	})
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := ssh.MarshalAuthorizedKey(sshPub)
+
+	for _, good := range [][]byte{privPEM, encryptedPEM} {
+		if err := validatePrivateKey(string(good)); err != nil {
+			t.Errorf("%s: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "nope", string(authorized)} {
+		if err := validatePrivateKey(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+
+	if err := validatePublicKey(string(authorized)); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"", "ssh-ed25519 nope", string(privPEM)} {
+		if err := validatePublicKey(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	t.Parallel()
+
+	for _, good := range []string{"https://example.com", "http://localhost:8080/login", "ftp://example.com/file"} {
+		if err := validateURL(good); err != nil {
+			t.Errorf("%q: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"example.com", "/login", "mailto:bob@example.com", "http://[::1"} {
+		if err := validateURL(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestValidateEmailAndDate(t *testing.T) {
+	t.Parallel()
+
+	if err := validateEmail("bob@example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := validateEmail("bob"); err == nil {
+		t.Error("expected an error")
+	}
+
+	if err := validateDate("2026-10-16"); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"2026-13-01", "16/10/2026", "2026-10-16 12:00"} {
+		if err := validateDate(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	t.Parallel()
+
+	schema, ok := FindSchema(TypeCard)
+	if !ok {
+		t.Fatal("card should be a type")
+	}
+
+	good := Blob{
+		KeyType:       TypeCard,
+		KeyCardholder: "Bob",
+		KeyNumber:     "4111 1111 1111 1111",
+		KeyExpiry:     "10/26",
+		KeyCVV:        "123",
+	}
+	if errs := schema.Validate(good); len(errs) != 0 {
+		t.Error("expected no errors:", errs)
+	}
+
+	bad := Blob{
+		KeyType:   TypeCard,
+		KeyNumber: "4111 1111 1111 1112",
+		KeyCVV:    "12",
+		// Not part of the schema so anything goes
+		"color": "blue",
+	}
+	errs := schema.Validate(bad)
+
+	want := []struct {
+		Message string
+		Invalid bool
+	}{
+		{"cardholder is required", false},
+		{"number is not valid: checksum is wrong, check for typos", true},
+		{"expiry is required", false},
+		{"cvv is not valid: must be 3 to 4 digits", true},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("wrong number of errors: %v", errs)
+	}
+	for i, w := range want {
+		if errs[i].Error() != w.Message || IsInvalidValue(errs[i]) != w.Invalid {
+			t.Errorf("%d) error was wrong: %v", i, errs[i])
+		}
+	}
+
+	if _, ok := FindSchema("spaceship"); ok {
+		t.Error("spaceship is not a type")
+	}
+	if typ := (Blob{}).Type(); typ != TypeLogin {
+		t.Error("entries without a type are logins:", typ)
+	}
+}
+
+func TestIsSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Blob   Blob
+		Key    string
+		Secret bool
+	}{
+		// Secret everywhere
+		{Blob{}, KeyPass, true},
+		{Blob{}, "PASS", true},
+		{Blob{}, KeyTwoFactor, true},
+		{Blob{KeyType: TypeNote}, KeyPass, true},
+		{Blob{KeyType: "spaceship"}, KeyPriv, true},
+		// Secret in the type
+		{Blob{KeyType: TypeCard}, KeyNumber, true},
+		{Blob{KeyType: TypeCard}, KeyCVV, true},
+		{Blob{KeyType: TypeCard}, KeyPIN, true},
+		{Blob{KeyType: TypeSSHKey}, KeyPassphrase, true},
+		{Blob{KeyType: TypeCard}, KeyCardholder, false},
+		{Blob{}, KeyNumber, false},
+		{Blob{KeyType: "spaceship"}, KeyNumber, false},
+		// Marked secret in the entry
+		{Blob{KeySecrets: "answer,hint"}, "hint", true},
+		{Blob{KeySecrets: "answer,hint"}, "answer", true},
+		{Blob{KeySecrets: "answer,hint"}, "question", false},
+		{Blob{}, KeyUser, false},
+		{Blob{}, KeyNotes, false},
+	}
+
+	for _, test := range tests {
+		if got := test.Blob.IsSecret(test.Key); got != test.Secret {
+			t.Errorf("%v %s: want %t", test.Blob, test.Key, test.Secret)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package blobformat
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// weakEntropy is the number of bits of entropy below which a password is
+// weak, a random 9 character password using letters and digits is just
+// above it
+const weakEntropy = 50
+
+// commonPasswords are some of the most used passwords, a password that is
+// one of these with digits or symbols tacked on is still weak
+var commonPasswords = []string{
+	"123456", "password", "qwerty", "abc123", "letmein", "welcome",
+	"monkey", "dragon", "football", "baseball", "iloveyou", "admin",
+	"login", "master", "sunshine", "princess", "shadow", "superman",
+	"trustno1", "passw0rd", "starwars", "whatever", "hello", "freedom",
+	"secret", "changeme", "default", "access", "michael", "jordan",
+}
+
+// keyboardRows are used to find walks along the keyboard like qwerty
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// PasswordEntropy estimates the bits of entropy in a password from the kinds
+// of characters it uses. Characters that repeat or continue a sequence
+// (abc, 321, qwe) are counted as a single bit since guessers try those
+// first.
+func PasswordEntropy(pass string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, c := range pass {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, kind := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if kind.used {
+			pool += kind.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	runes := []rune(strings.ToLower(pass))
+	bits := 0.0
+	for i, c := range runes {
+		if i > 0 && predictable(runes[i-1], c) {
+			bits++
+		} else {
+			bits += perChar
+		}
+	}
+	return bits
+}
+
+// predictable checks if c is what a guesser would try after prev
+func predictable(prev, c rune) bool {
+	if c == prev || c == prev+1 || c == prev-1 {
+		return true
+	}
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, prev)
+		if i < 0 {
+			continue
+		}
+		if (i+1 < len(row) && rune(row[i+1]) == c) || (i > 0 && rune(row[i-1]) == c) {
+			return true
+		}
+	}
+	return false
+}
+
+// weakPassword returns why a password is weak, or an empty string if it
+// isn't. related are things like the name of the entry and the username
+// which shouldn't be part of the password.
+func weakPassword(pass string, related ...string) string {
+	lower := strings.ToLower(pass)
+	trimmed := strings.TrimRightFunc(lower, func(c rune) bool {
+		return !unicode.IsLetter(c)
+	})
+	for _, common := range commonPasswords {
+		if lower == common || trimmed == common {
+			return "is a common password"
+		}
+	}
+
+	for _, r := range related {
+		r = strings.ToLower(r)
+		if len(r) >= 3 && strings.Contains(lower, r) {
+			return fmt.Sprintf("contains %q", r)
+		}
+	}
+
+	if bits := PasswordEntropy(pass); bits < weakEntropy {
+		return fmt.Sprintf("is easy to guess (about %d bits of entropy)", int(bits))
+	}
+	return ""
+}
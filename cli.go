@@ -11,6 +11,7 @@ import (
 
 const (
 	historyLayout = "2006-01-02 15:04:05"
+	dateLayout    = "2006-01-02"
 )
 
 var (
@@ -20,11 +21,23 @@ var (
 	flagNoColor     bool
 	flagNoClearClip bool
 	flagNoAutoSync  bool
+	flagCompress    bool
+	flagNoCompress  bool
 	flagTime        string
 	flagFile        string
+	flagCodec       string
 
 	flagExportFormat   string
 	flagExportFilename string
+	flagExportReveal   bool
+
+	flagLogQuery string
+	flagLogSince string
+	flagLogUntil string
+	flagLogKind  string
+	flagLogUser  string
+
+	flagAuditAge int
 )
 
 var (
@@ -32,6 +45,9 @@ var (
 	genCmd         = flaggy.NewSubcommand("gen")
 	lpassImportCmd = flaggy.NewSubcommand("lpassimport")
 	exportCmd      = flaggy.NewSubcommand("export")
+	logCmd         = flaggy.NewSubcommand("log")
+	fsckCmd        = flaggy.NewSubcommand("fsck")
+	auditCmd       = flaggy.NewSubcommand("audit")
 )
 
 func parseCli() {
@@ -46,19 +62,35 @@ func parseCli() {
 	parser.Bool(&flagNoColor, "", "no-color", "Turn off color output")
 	parser.Bool(&flagNoAutoSync, "", "no-sync", "Do not sync the file automatically")
 	parser.Bool(&flagNoClearClip, "", "no-clear-clip", "Do not clear clipboard on exit")
+	parser.Bool(&flagCompress, "", "compress", "Save the file compressed (older versions of bpass can't open it)")
+	parser.Bool(&flagNoCompress, "", "no-compress", "Save the file without compressing it")
 	parser.Bool(&flagHelp, "h", "help", "Show help")
 	parser.String(&flagTime, "t", "time", "Open the file read-only at a time in the past (YYYY-MM-DD HH:mm:ss)")
 	parser.String(&flagFile, "f", "file", "The file to open (can be set by $BPASS)")
+	parser.String(&flagCodec, "", "codec", "Change the encoding the file is saved with (json, msgpack)")
 
 	versionCmd.Description = "print version and exit"
 	lpassImportCmd.Description = "import lastpass csv by running `lpass export`"
 	genCmd.Description = "generate a password"
 	exportCmd.Description = "export the database"
+	logCmd.Description = "print the history of changes as json"
+	fsckCmd.Description = "check the file for problems and repair them"
+	auditCmd.Description = "check the passwords for reuse, weakness, age and more"
 
 	flagExportFormat = "CSV"
 	exportCmd.String(&flagExportFormat, "", "format", "The format to output")
+	exportCmd.Bool(&flagExportReveal, "", "reveal", "Export secret values instead of masking them")
 	exportCmd.AddPositionalValue(&flagExportFilename, "output", 1, true, "Export filename")
 
+	logCmd.String(&flagLogSince, "", "since", "Only changes at or after (YYYY-MM-DD [HH:MM:SS])")
+	logCmd.String(&flagLogUntil, "", "until", "Only changes at or before (YYYY-MM-DD [HH:MM:SS])")
+	logCmd.String(&flagLogKind, "", "kind", "Only changes of these kinds (add,del,setk,delk,...)")
+	logCmd.String(&flagLogUser, "", "user", "Only changes made by this user")
+	logCmd.AddPositionalValue(&flagLogQuery, "query", 1, false, "Only changes to entries matching query")
+
+	flagAuditAge = defaultAuditAge
+	auditCmd.Int(&flagAuditAge, "", "age", "Report passwords older than this many days (0 to not check)")
+
 	parser.AdditionalHelpAppend = "bpass respects $BPASS, $EDITOR, $PINENTRY env vars\n$PINENTRY can be set to none to prevent it from using pinentry"
 
 	parser.ShowHelpWithHFlag = false
@@ -75,6 +107,9 @@ func parseCli() {
 	parser.AttachSubcommand(genCmd, 1)
 	parser.AttachSubcommand(lpassImportCmd, 1)
 	parser.AttachSubcommand(exportCmd, 1)
+	parser.AttachSubcommand(logCmd, 1)
+	parser.AttachSubcommand(fsckCmd, 1)
+	parser.AttachSubcommand(auditCmd, 1)
 	parser.Parse()
 
 	if flagFile == defaultFilePath {
@@ -85,7 +120,7 @@ func parseCli() {
 	}
 	if len(flagTime) != 0 {
 		var err error
-		historyTime, err = time.Parse(historyLayout, flagTime)
+		historyTime, err = time.ParseInLocation(historyLayout, flagTime, time.Local)
 		if err != nil {
 			fmt.Println("failed to parse the date flag, format:", historyLayout)
 			os.Exit(1)
@@ -27,6 +27,7 @@ import (
 	"github.com/aarondl/bpass/blobformat"
 	"github.com/aarondl/bpass/crypt"
 	"github.com/aarondl/bpass/osutil"
+	"github.com/aarondl/bpass/txlogs"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/aarondl/color"
@@ -59,7 +60,7 @@ func (u *uiContext) passwd(user string) error {
 		return nil
 	}
 
-	key, salt, err := crypt.DeriveKey(cryptVersion, []byte(pass))
+	key, salt, err := crypt.DeriveKey(keyVersion, []byte(pass))
 	if err != nil {
 		return err
 	}
@@ -78,7 +79,7 @@ func (u *uiContext) passwd(user string) error {
 			return err
 		}
 
-		mkey, iv, err := crypt.EncryptMasterKey(cryptVersion, key, u.master)
+		mkey, iv, err := crypt.EncryptMasterKey(keyVersion, key, u.master)
 		if err != nil {
 			return err
 		}
@@ -104,12 +105,13 @@ func (u *uiContext) adduser(user string) error {
 	var key, salt []byte
 	var pass string
 	if len(u.master) == 0 {
-		u.master, u.ivm, err = crypt.NewMasterKey(cryptVersion)
+		u.master, u.ivm, err = crypt.NewMasterKey(keyVersion)
 		if err != nil {
 			return nil
 		}
 
 		u.user = user
+		u.store.User = user
 		key = u.key
 		salt = u.salt
 	} else {
@@ -118,13 +120,13 @@ func (u *uiContext) adduser(user string) error {
 			return err
 		}
 
-		key, salt, err = crypt.DeriveKey(cryptVersion, []byte(pass))
+		key, salt, err = crypt.DeriveKey(keyVersion, []byte(pass))
 		if err != nil {
 			return err
 		}
 	}
 
-	mkey, iv, err := crypt.EncryptMasterKey(cryptVersion, key, u.master)
+	mkey, iv, err := crypt.EncryptMasterKey(keyVersion, key, u.master)
 	if err != nil {
 		return err
 	}
@@ -162,7 +164,7 @@ func (u *uiContext) rekey(user string) error {
 		return nil
 	}
 
-	key, salt, err := crypt.DeriveKey(cryptVersion, []byte(pass))
+	key, salt, err := crypt.DeriveKey(keyVersion, []byte(pass))
 	if err != nil {
 		return err
 	}
@@ -186,7 +188,7 @@ func (u *uiContext) rekey(user string) error {
 			return err
 		}
 
-		mkey, iv, err := crypt.EncryptMasterKey(cryptVersion, key, u.master)
+		mkey, iv, err := crypt.EncryptMasterKey(keyVersion, key, u.master)
 		if err != nil {
 			return err
 		}
@@ -221,7 +223,7 @@ func (u *uiContext) rekeyAll() error {
 		return nil
 	}
 
-	master, ivm, err := crypt.NewMasterKey(cryptVersion)
+	master, ivm, err := crypt.NewMasterKey(keyVersion)
 	if err != nil {
 		return err
 	}
@@ -247,7 +249,7 @@ func (u *uiContext) rekeyAll() error {
 			return err
 		}
 
-		key, salt, err := crypt.DeriveKey(cryptVersion, []byte(pass))
+		key, salt, err := crypt.DeriveKey(keyVersion, []byte(pass))
 		if err != nil {
 			return err
 		}
@@ -259,7 +261,7 @@ func (u *uiContext) rekeyAll() error {
 			u.salt = salt
 		}
 
-		mkey, iv, err := crypt.EncryptMasterKey(cryptVersion, key, u.master)
+		mkey, iv, err := crypt.EncryptMasterKey(keyVersion, key, u.master)
 		if err != nil {
 			return err
 		}
@@ -468,8 +470,8 @@ func addSCPEntry(u *uiContext, uuid string) (uri url.URL, err error) {
 	return uri, nil
 }
 
-func (u *uiContext) addNewInterruptible(name string) error {
-	err := u.addNew(name)
+func (u *uiContext) addNewInterruptible(name, typ string) error {
+	err := u.addNew(name, typ)
 	switch err {
 	case nil:
 		return nil
@@ -481,7 +483,24 @@ func (u *uiContext) addNewInterruptible(name string) error {
 	}
 }
 
-func (u *uiContext) addNew(name string) (err error) {
+// addNew adds an entry of a type, asking for the type if it's not given and
+// then for each of the type's fields
+func (u *uiContext) addNew(name, typ string) (err error) {
+	if len(typ) == 0 {
+		types := blobformat.Types()
+		choice, err := u.getMenuChoice("type: ", types)
+		if err != nil {
+			return err
+		}
+		typ = types[choice]
+	}
+
+	schema, ok := blobformat.FindSchema(typ)
+	if !ok {
+		errColor.Printf("unknown type %q, use one of: %s\n", typ, strings.Join(blobformat.Types(), ", "))
+		return nil
+	}
+
 	return u.store.Do(func() error {
 		uuid, err := u.store.New(name)
 		if err != nil {
@@ -492,35 +511,66 @@ func (u *uiContext) addNew(name string) (err error) {
 			return err
 		}
 
-		email, err := u.prompt(promptColor.Sprint("email: "))
-		if err != nil {
-			return err
-		}
+		// Use raw sets here to avoid creating history spam based on timestamp
+		// additions
+		u.store.DB.Set(uuid, blobformat.KeyType, typ)
+
+		for _, f := range schema.Fields {
+			switch f.Key {
+			case blobformat.KeyTwoFactor, blobformat.KeyLabels:
+				// These have their own commands
+				continue
+			case blobformat.KeyNotes:
+				if !f.Required {
+					continue
+				}
+			}
 
-		user, err := u.prompt(promptColor.Sprint("user: "))
-		if err != nil {
-			return err
+			value, err := u.getField(f)
+			if err != nil {
+				return err
+			}
+			if len(value) != 0 {
+				u.store.DB.Set(uuid, f.Key, value)
+			}
 		}
 
-		pass, err := u.getPassword()
-		if err != nil {
-			return err
-		}
+		return nil
+	})
+}
 
-		// Use raw sets here to avoid creating history spam based on timestamp
-		// additions
-		if len(user) != 0 {
-			u.store.DB.Set(uuid, blobformat.KeyUser, user)
-		}
-		if len(email) != 0 {
-			u.store.DB.Set(uuid, blobformat.KeyEmail, email)
+// getField asks for the value of a field until it's valid, optional fields
+// can be left empty
+func (u *uiContext) getField(f blobformat.Field) (value string, err error) {
+	for {
+		switch {
+		case f.Key == blobformat.KeyPass:
+			value, err = u.getPassword()
+		case f.Multiline:
+			infoColor.Printf("%s:\n", f.Key)
+			value, err = u.promptMultiline(promptColor.Sprint("> "))
+		case f.Secret:
+			value, err = u.promptPassword(promptColor.Sprintf("%s: ", f.Key))
+		default:
+			value, err = u.prompt(promptColor.Sprintf("%s: ", f.Key))
 		}
-		if len(pass) != 0 {
-			u.store.DB.Set(uuid, blobformat.KeyPass, pass)
+		if err != nil {
+			return "", err
 		}
 
-		return nil
-	})
+		switch {
+		case len(value) == 0 && f.Required:
+			errColor.Println(f.Key, "cannot be empty")
+		case len(value) == 0 || f.Validate == nil:
+			return value, nil
+		default:
+			if err := f.Validate(value); err != nil {
+				errColor.Printf("%s is not valid: %v\n", f.Key, err)
+				continue
+			}
+			return value, nil
+		}
+	}
 }
 
 func (u *uiContext) rename(src, dst string) error {
@@ -571,7 +621,7 @@ func (u *uiContext) deleteEntry(name string) error {
 	}
 
 	errColor.Printf("WARNING: This will delete all data associated with %q\n", name)
-	errColor.Println("Including ALL history irrecoverably, are you sure you wish to proceed?")
+	errColor.Println("It can be restored with undelete until the history is compacted, are you sure you wish to proceed?")
 	fmt.Println()
 
 	line, err := u.prompt(promptColor.Sprintf("type %q to proceed: ", name))
@@ -597,6 +647,66 @@ func (u *uiContext) deleteEntry(name string) error {
 	return nil
 }
 
+// trash lists the entries that were deleted
+func (u *uiContext) trash() error {
+	deleted, err := u.store.Trash()
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		infoColor.Println("trash is empty")
+		return nil
+	}
+
+	for _, d := range deleted {
+		fmt.Fprintf(u.out, "%s %s %s\n",
+			d.Time.Format(historyLayout),
+			keyColor.Sprint(d.UUID),
+			blobformat.Blob(d.Entry).Name(),
+		)
+	}
+
+	return nil
+}
+
+// undelete restores a deleted entry by uuid or name, if more than one
+// deleted entry had the name the uuid must be used.
+func (u *uiContext) undelete(nameOrUUID string) error {
+	deleted, err := u.store.Trash()
+	if err != nil {
+		return err
+	}
+
+	var matches []txlogs.Deleted
+	for _, d := range deleted {
+		if d.UUID == nameOrUUID || blobformat.Blob(d.Entry).Name() == nameOrUUID {
+			matches = append(matches, d)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		errColor.Printf("%q is not in the trash\n", nameOrUUID)
+		return nil
+	case 1:
+	default:
+		errColor.Printf("more than one %q is in the trash, use the uuid from trash\n", nameOrUUID)
+		return nil
+	}
+
+	name := blobformat.Blob(matches[0].Entry).Name()
+	if _, err = u.store.Undelete(matches[0].UUID); err == blobformat.ErrNameNotUnique {
+		errColor.Println(name, "already exists, rename it first")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	infoColor.Printf("restored %q\n", name)
+	return nil
+}
+
 func (u *uiContext) deleteKey(search, key string) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
@@ -655,7 +765,104 @@ func (u *uiContext) listByLabels(wantLabels []string) error {
 	return nil
 }
 
-func (u *uiContext) get(search, key string, index int, copy bool) error {
+// due lists the entries that have expired or should be rotated, or will
+// within days
+func (u *uiContext) due(days int) error {
+	due, err := u.store.Due(time.Now().AddDate(0, 0, days))
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		infoColor.Println("nothing is due")
+		return nil
+	}
+
+	width := 0
+	for _, d := range due {
+		if len(d.Name) > width {
+			width = len(d.Name)
+		}
+	}
+
+	for _, d := range due {
+		if d.Err != nil {
+			errColor.Println(d.Err)
+			continue
+		}
+
+		reason := "expires"
+		if d.Reason == blobformat.KeyRotateEvery {
+			reason = "rotate "
+		}
+
+		fmt.Fprintf(u.out, "%-*s %s %s (%s)\n",
+			width, d.Name,
+			keyColor.Sprint(reason),
+			d.When.Format(dateLayout),
+			dueIn(d.When, time.Now()),
+		)
+	}
+
+	return nil
+}
+
+// dueIn says how many days until when in calendar days so that something
+// due later today isn't "in 1 day" and something that was due this morning
+// isn't "0 days overdue"
+func dueIn(when, now time.Time) string {
+	day := func(t time.Time) time.Time {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+	// Rounded since a day isn't always 24 hours when the clocks change
+	days := int(math.Round(day(when).Sub(day(now)).Hours() / 24))
+
+	plural := "s"
+	if days == 1 || days == -1 {
+		plural = ""
+	}
+
+	switch {
+	case days < 0:
+		return errColor.Sprintf("%d day%s overdue", -days, plural)
+	case when.Before(now):
+		return errColor.Sprint("overdue today")
+	case days == 0:
+		return "today"
+	default:
+		return fmt.Sprintf("in %d day%s", days, plural)
+	}
+}
+
+// warnOverdue warns about entries that are overdue, see due
+func (u *uiContext) warnOverdue() {
+	due, err := u.store.Due(time.Now())
+	if err != nil {
+		errColor.Println("failed to check for overdue entries:", err)
+		return
+	}
+
+	overdue := due[:0]
+	for _, d := range due {
+		if d.Err != nil {
+			errColor.Println("failed to check if overdue:", d.Err)
+			continue
+		}
+		overdue = append(overdue, d)
+	}
+
+	switch len(overdue) {
+	case 0:
+	case 1:
+		errColor.Printf("%s is overdue for a password change, see \"due\"\n", overdue[0].Name)
+	default:
+		errColor.Printf("%d entries are overdue for a password change, see \"due\"\n", len(overdue))
+	}
+}
+
+// get shows or copies a key of an entry. If past is above 0 it's a value from
+// the key's history numbered as in keyHistory instead of the current one.
+func (u *uiContext) get(search, key string, index, past int, copy bool) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
 		return err
@@ -706,6 +913,17 @@ func (u *uiContext) get(search, key string, index int, copy bool) error {
 		}
 	default:
 		value, ok := blob[key]
+		if past > 0 {
+			values, err := u.store.Values(uuid, key)
+			if err != nil {
+				return err
+			}
+			if past > len(values) {
+				errColor.Printf("%s.%s only has %d values in its history\n", blob.Name(), key, len(values))
+				return nil
+			}
+			value, ok = values[past-1].Value, true
+		}
 		if !ok {
 			errColor.Printf("%s.%s is not set", blob.Name(), key)
 			return nil
@@ -775,7 +993,10 @@ func (u *uiContext) login(search string) error {
 	return nil
 }
 
-func (u *uiContext) set(search, key, value string) error {
+// set a key on an entry, if secret is true the key is also marked secret in
+// the entry. Marking a key that already has a value secret doesn't need a
+// new value.
+func (u *uiContext) set(search, key, value string, secret bool) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
 		return err
@@ -784,6 +1005,20 @@ func (u *uiContext) set(search, key, value string) error {
 		return nil
 	}
 
+	if secret && len(value) == 0 {
+		blob, err := u.store.MustFind(uuid)
+		if err != nil {
+			return err
+		}
+		if len(blob[key]) != 0 {
+			if err = u.store.MarkSecret(uuid, key); err != nil {
+				return err
+			}
+			infoColor.Println(key, "is now secret")
+			return nil
+		}
+	}
+
 	switch key {
 	case blobformat.KeyPass:
 		if len(value) == 0 {
@@ -794,15 +1029,15 @@ func (u *uiContext) set(search, key, value string) error {
 			}
 		}
 
-		u.store.Set(uuid, key, value)
+		err = u.store.Set(uuid, key, value)
 	case blobformat.KeyTwoFactor:
 		if err := u.store.SetTwofactor(uuid, value); err != nil {
 			errColor.Println(err)
 			return nil
 		}
 	case blobformat.KeyURL:
-		uri, err := url.Parse(value)
-		if err != nil {
+		uri, parseErr := url.Parse(value)
+		if parseErr != nil {
 			errColor.Println("not a valid url")
 			return nil
 		} else if uri.Scheme == "" || uri.Opaque != "" {
@@ -810,7 +1045,7 @@ func (u *uiContext) set(search, key, value string) error {
 			return nil
 		}
 
-		u.store.Set(uuid, key, value)
+		err = u.store.Set(uuid, key, value)
 	default:
 		// no known key was provided,  setting custom key
 
@@ -822,14 +1057,58 @@ func (u *uiContext) set(search, key, value string) error {
 			}
 		}
 
-		u.store.Set(uuid, key, value)
+		err = u.store.Set(uuid, key, value)
+	}
+	if blobformat.IsInvalidValue(err) || blobformat.IsKeyNotAllowed(err) {
+		errColor.Println(err)
+		return nil
+	} else if err != nil {
+		return err
 	}
 
+	if secret {
+		if err = u.store.MarkSecret(uuid, key); err != nil {
+			return err
+		}
+	}
+
+	blob, err := u.store.MustFind(uuid)
+	if err != nil {
+		return err
+	}
+	if blob.IsSecret(key) {
+		value = "********"
+	}
 	infoColor.Printf("set %s = %s\n", key, value)
 
 	return nil
 }
 
+func (u *uiContext) unmarkSecret(search, key string) error {
+	uuid, err := u.findOne(search)
+	if err != nil {
+		return err
+	}
+	if len(uuid) == 0 {
+		return nil
+	}
+
+	err = u.store.UnmarkSecret(uuid, key)
+	if err == blobformat.ErrNotSecret {
+		if blobformat.IsSecretKey(key) {
+			errColor.Println(key, "is always secret")
+		} else {
+			errColor.Println(key, "is not marked secret")
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	infoColor.Println(key, "is no longer secret")
+	return nil
+}
+
 func (u *uiContext) edit(search, key string) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
@@ -956,11 +1235,7 @@ func (u *uiContext) addLabels(search string) error {
 		return err
 	}
 
-	labelVal := blob[blobformat.KeyLabels]
-	var labels []string
-	if len(labelVal) != 0 {
-		labels = strings.Split(labelVal, ",")
-	}
+	labels := blob.Labels()
 
 	infoColor.Println("Enter labels, blank line, ctrl-d, or . to stop")
 	changed := false
@@ -985,7 +1260,11 @@ func (u *uiContext) addLabels(search string) error {
 	}
 
 	if changed {
-		u.store.Set(uuid, blobformat.KeyLabels, strings.Join(labels, ","))
+		for _, label := range labels[len(blob.Labels()):] {
+			if err = u.store.AddLabel(uuid, label); err != nil {
+				return err
+			}
+		}
 		infoColor.Println("Updated labels for", blob.Name())
 	}
 	return nil
@@ -1005,34 +1284,19 @@ func (u *uiContext) deleteLabel(search string, label string) error {
 		return err
 	}
 
-	labelVal := blob[blobformat.KeyLabels]
-	if len(labelVal) == 0 {
-		errColor.Println("Could not find that label")
-		return nil
-	}
-
-	labels := strings.Split(labelVal, ",")
-	index := -1
-	for i, l := range labels {
-		if l == label {
-			index = i
-			break
-		}
-	}
-
-	if index < 0 {
+	err = u.store.RemoveLabel(uuid, label)
+	if err == blobformat.ErrNoSuchLabel {
 		errColor.Println("Could not find that label")
 		return nil
-	}
-
-	if err = u.store.RemoveLabel(uuid, index); err != nil {
+	} else if err != nil {
 		return err
 	}
 	infoColor.Println("Updated labels for", blob.Name())
 	return nil
 }
 
-func (u *uiContext) show(search string, snapshot int) error {
+// show an entry, secret values are hidden unless reveal is true
+func (u *uiContext) show(search string, snapshot int, reveal bool) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
 		return err
@@ -1078,15 +1342,22 @@ func (u *uiContext) show(search string, snapshot int) error {
 	width *= -1
 	indent := 2
 
-	// Do these first
-	ordering := []string{
-		blobformat.KeyName,
-		blobformat.KeyUser,
-		blobformat.KeyEmail,
-		blobformat.KeyPass,
-		blobformat.KeyTwoFactor,
-		blobformat.KeyLabels,
-		blobformat.KeyNotes,
+	// Do these first, in the order the entry's type has them
+	ordering := []string{blobformat.KeyName, blobformat.KeyType}
+	schema, hasSchema := blob.Schema()
+	if hasSchema {
+		for _, f := range schema.Fields {
+			ordering = append(ordering, f.Key)
+		}
+	} else {
+		ordering = append(ordering,
+			blobformat.KeyUser,
+			blobformat.KeyEmail,
+			blobformat.KeyPass,
+			blobformat.KeyTwoFactor,
+			blobformat.KeyLabels,
+			blobformat.KeyNotes,
+		)
 	}
 
 	// Delete the ordering ones out of keys
@@ -1114,9 +1385,7 @@ func (u *uiContext) show(search string, snapshot int) error {
 		}
 
 		switch k {
-		case blobformat.KeyPass:
-			showHidden(u, blobformat.KeyPass, blob.Get(blobformat.KeyPass), width, indent)
-		case blobformat.KeyLabels:
+		case blobformat.KeyLabels, blobformat.KeySecrets:
 			showKeyValue(u, k, strings.ReplaceAll(val, ",", ", "), width, indent)
 		case blobformat.KeyTwoFactor:
 			t, err := blob.TwoFactor()
@@ -1126,9 +1395,15 @@ func (u *uiContext) show(search string, snapshot int) error {
 				showKeyValue(u, blobformat.KeyTwoFactor, t, width, indent)
 			}
 		default:
-			if strings.ContainsRune(val, '\n') {
+			secret := !reveal && blob.IsSecret(k)
+			switch {
+			case strings.ContainsRune(val, '\n') && secret:
+				showMultiline(u, k, hideLines(val), width, indent)
+			case strings.ContainsRune(val, '\n'):
 				showMultiline(u, k, val, width, indent)
-			} else {
+			case secret:
+				showHidden(u, k, val, width, indent)
+			default:
 				showKeyValue(u, k, val, width, indent)
 			}
 		}
@@ -1144,6 +1419,12 @@ func (u *uiContext) show(search string, snapshot int) error {
 		showKeyValue(u, "snaps", strconv.Itoa(snaps), width, indent)
 	}
 
+	if hasSchema {
+		for _, err := range schema.Validate(blob) {
+			errColor.Println(err)
+		}
+	}
+
 	return nil
 }
 
@@ -1157,6 +1438,15 @@ func showHidden(u *uiContext, key, value string, width, indent int) {
 	fmt.Fprintf(u.out, "%s%s %s\n", ind, keyColor.Sprintf("%*s", width, key+":"), hideColor.Sprint(value))
 }
 
+// hideLines hides each line of a multi-line value
+func hideLines(val string) string {
+	lines := strings.Split(val, "\n")
+	for i, l := range lines {
+		lines[i] = hideColor.Sprint(l)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func showMultiline(u *uiContext, key string, val string, width, indent int) {
 	lines := strings.Split(val, "\n")
 
@@ -1204,7 +1494,9 @@ func (u *uiContext) openurl(search string) error {
 	return nil
 }
 
-func (u *uiContext) dump(search string) error {
+// dump an entry's raw keys and values, secret values are masked unless
+// reveal is true
+func (u *uiContext) dump(search string, reveal bool) error {
 	uuid, err := u.findOne(search)
 	if err != nil {
 		return err
@@ -1217,20 +1509,151 @@ func (u *uiContext) dump(search string) error {
 	if err != nil {
 		return err
 	}
+	if !reveal {
+		blob = maskEntry(blob)
+	}
 	dumpBlob(blob, 0)
 
 	return nil
 }
 
-func (u *uiContext) dumpall() error {
-	b, err := json.MarshalIndent(u.store.DB, "", "  ")
+// dumpall dumps the whole store, secret values are masked unless reveal is
+// true
+func (u *uiContext) dumpall(reveal bool) error {
+	db := u.store.DB
+	if !reveal {
+		if err := db.UpdateSnapshot(); err != nil {
+			return err
+		}
+		masked, err := maskDB(db)
+		if err != nil {
+			return err
+		}
+		db = masked
+	}
+
+	b, err := json.MarshalIndent(db, "", "  ")
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%s\n", b)
+	fmt.Fprintf(u.out, "%s\n", b)
 	return nil
 }
 
+// maskValue masks a value if it's not empty
+func maskValue(value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	return "********"
+}
+
+// maskEntry returns a copy of the entry with its secret values masked, keys
+// that are secret in one of also are masked as well
+func maskEntry(blob blobformat.Blob, also ...blobformat.Blob) blobformat.Blob {
+	masked := make(blobformat.Blob, len(blob))
+	for k, v := range blob {
+		secret := blob.IsSecret(k)
+		for _, a := range also {
+			secret = secret || a.IsSecret(k)
+		}
+		if secret {
+			v = maskValue(v)
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+// secretTxs works out which transactions in the log set a secret value. A
+// value is secret if its key was secret in the entry when it was set or is
+// secret in the entry as it last was, which for entries in the trash is
+// right before they were deleted. last has the entries as they last were.
+func secretTxs(db *txlogs.DB) (secret []bool, last map[string]blobformat.Blob, err error) {
+	secret = make([]bool, len(db.Log))
+	last = make(map[string]blobformat.Blob)
+
+	err = db.Walk(func(i int, tx txlogs.Tx, snap map[string]txlogs.Entry) error {
+		if tx.Kind == txlogs.TxCheckpoint {
+			for uuid, entry := range snap {
+				last[uuid] = blobformat.Blob(entry)
+			}
+			return nil
+		}
+
+		// Entries are changed in place so this keeps up with the entry
+		// until it's deleted
+		entry, ok := snap[tx.UUID]
+		if !ok {
+			return nil
+		}
+		last[tx.UUID] = blobformat.Blob(entry)
+		secret[i] = blobformat.Blob(entry).IsSecret(tx.Key)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, tx := range db.Log {
+		if !secret[i] && len(tx.UUID) != 0 {
+			secret[i] = last[tx.UUID].IsSecret(tx.Key)
+		}
+	}
+	return secret, last, nil
+}
+
+// maskDB returns a copy of the parts of db that are dumped with the secret
+// values masked, including the snapshots held by checkpoints. See secretTxs
+// for how secret values in the log are found.
+func maskDB(db *txlogs.DB) (*txlogs.DB, error) {
+	secret, last, err := secretTxs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	maskSnapshot := func(snapshot map[string]txlogs.Entry) map[string]txlogs.Entry {
+		masked := make(map[string]txlogs.Entry, len(snapshot))
+		for uuid, entry := range snapshot {
+			masked[uuid] = txlogs.Entry(maskEntry(blobformat.Blob(entry), last[uuid]))
+		}
+		return masked
+	}
+
+	masked := &txlogs.DB{
+		Version:  db.Version,
+		Snapshot: maskSnapshot(db.Snapshot),
+		Log:      make([]txlogs.Tx, len(db.Log)),
+		Points:   make([]txlogs.SnapshotPoint, len(db.Points)),
+	}
+
+	for i, tx := range db.Log {
+		switch {
+		case tx.Kind == txlogs.TxCheckpoint:
+			var snapshot map[string]txlogs.Entry
+			if err := json.Unmarshal([]byte(tx.Value), &snapshot); err != nil {
+				return nil, fmt.Errorf("checkpoint is corrupt: %w", err)
+			}
+			value, err := json.Marshal(maskSnapshot(snapshot))
+			if err != nil {
+				return nil, err
+			}
+			tx.Value = string(value)
+		case secret[i] && tx.Kind == txlogs.TxSetKey:
+			tx.Value = maskValue(tx.Value)
+			// With the salt the value could be guessed from the chain
+			tx.Salt = ""
+		}
+		masked.Log[i] = tx
+	}
+	for i, p := range db.Points {
+		p.Snapshot = maskSnapshot(p.Snapshot)
+		masked.Points[i] = p
+	}
+
+	return masked, nil
+}
+
 func dumpBlob(blob map[string]string, indent int) {
 	for k, v := range blob {
 		fmt.Printf("%s%s: %#v\n", strings.Repeat(" ", indent), k, v)
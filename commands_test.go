@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarondl/bpass/blobformat"
+	"github.com/aarondl/bpass/txlogs"
+)
+
+func TestDumpallMasksDeleted(t *testing.T) {
+	t.Parallel()
+
+	const kept, deleted = "4111111111111111", "5555555555554444"
+
+	db := new(txlogs.DB)
+	addCard := func(name, number string) string {
+		uuid, err := db.Add()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Set(uuid, blobformat.KeyName, name)
+		db.Set(uuid, blobformat.KeyType, blobformat.TypeCard)
+		db.Set(uuid, blobformat.KeyNumber, number)
+		return uuid
+	}
+
+	// One card is folded into a checkpoint, the other is in the trash
+	addCard("visa", kept)
+	if _, err := db.Compact(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	db.Delete(addCard("mastercard", deleted))
+
+	var out bytes.Buffer
+	u := uiContext{out: &out, store: blobformat.Blobs{DB: db}}
+	if err := u.dumpall(false); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := out.String()
+	if strings.Contains(dump, kept) {
+		t.Error("the number in the checkpoint was not masked")
+	}
+	if strings.Contains(dump, deleted) {
+		t.Error("the number of the deleted card was not masked")
+	}
+
+	out.Reset()
+	if err := u.dumpall(true); err != nil {
+		t.Fatal(err)
+	}
+	if dump = out.String(); !strings.Contains(dump, kept) || !strings.Contains(dump, deleted) {
+		t.Error("reveal should show the numbers")
+	}
+}
+
+var colorCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestDueIn(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		When time.Time
+		Want string
+	}{
+		{now.Add(-time.Hour), "overdue today"},
+		{now.Add(-13 * time.Hour), "1 day overdue"},
+		{now.AddDate(0, 0, -3), "3 days overdue"},
+		{now.Add(time.Hour), "today"},
+		{now.Add(13 * time.Hour), "in 1 day"},
+		{now.AddDate(0, 0, 14), "in 14 days"},
+	}
+
+	for _, test := range tests {
+		if got := colorCodes.ReplaceAllString(dueIn(test.When, now), ""); got != test.Want {
+			t.Errorf("%v: want %q, got %q", test.When, test.Want, got)
+		}
+	}
+}
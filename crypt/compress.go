@@ -0,0 +1,55 @@
+package crypt
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// Compressions of the plaintext, the numbers are stored in files so they
+// must not change
+const (
+	CompressionNone    = 0
+	CompressionDeflate = 1
+)
+
+// compress the plaintext before it's encrypted
+func compress(compression int, plaintext []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return plaintext, nil
+	case CompressionDeflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+}
+
+// decompress the plaintext after it's decrypted
+func decompress(compression int, compressed []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return compressed, nil
+	case CompressionDeflate:
+		r := flate.NewReader(bytes.NewReader(compressed))
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		return plaintext, r.Close()
+	default:
+		return nil, fmt.Errorf("unknown compression %d, try upgrading bpass", compression)
+	}
+}
@@ -32,7 +32,13 @@ const (
 	magicLen = 16
 	magicStr = "blobpass"
 
-	maxVersion = 9999
+	// encodingLen and compressionLen are the sizes of the encoding and the
+	// compression in the header of versions that have them
+	encodingLen    = 4
+	compressionLen = 4
+
+	maxVersion  = 9999
+	maxEncoding = 9999
 )
 
 // v0Header is a special case
@@ -49,6 +55,10 @@ type config struct {
 	saltSize  int
 	keySize   int
 	blockSize int
+	// headerLen is the size of the fixed part of the header: the magic,
+	// the number of users and the encoding and compression if the version
+	// has them
+	headerLen int
 
 	// these functions must be set for the config to be able to do anything
 	encrypt    encryptFn
@@ -88,6 +98,16 @@ var (
 func init() {
 	// Create all the versioned configurations
 	makeVersion(1, encryptV1, encryptMasterKeyV1, decryptV1, deriveKeyV1, newMasterKeyV1, 32, "AES", "Camellia", "CAST5")
+
+	// Version 2 is version 1 with the encoding of the plaintext in the header
+	v2 := makeVersion(2, encryptV1, encryptMasterKeyV1, decryptV1, deriveKeyV1, newMasterKeyV1, 32, "AES", "Camellia", "CAST5")
+	v2.headerLen += encodingLen
+	versions[2] = v2
+
+	// Version 3 adds the compression of the plaintext to the header
+	v3 := makeVersion(3, encryptV1, encryptMasterKeyV1, decryptV1, deriveKeyV1, newMasterKeyV1, 32, "AES", "Camellia", "CAST5")
+	v3.headerLen += encodingLen + compressionLen
+	versions[3] = v3
 }
 
 // makeVersion is a helper for calculating block and key size from the
@@ -96,6 +116,7 @@ func makeVersion(version int, e encryptFn, ek encryptMKeyFn, d decryptFn, k keyF
 	c := config{
 		version:    version,
 		saltSize:   saltSize,
+		headerLen:  magicLen,
 		encrypt:    e,
 		encryptKey: ek,
 		decrypt:    d,
@@ -209,6 +209,133 @@ func TestCryptMulti(t *testing.T) {
 	}
 }
 
+func TestCryptEncoding(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte("plaintext goes here")
+
+	c, err := getVersion(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{1}, c.keySize)
+	salt := bytes.Repeat([]byte{2}, c.saltSize)
+
+	p := Params{Keys: [][]byte{key}, Salts: [][]byte{salt}, Encoding: 1}
+	if _, err := Encrypt(1, &p, plaintext); err == nil {
+		t.Error("version 1 should not be able to record an encoding")
+	}
+
+	ciphertext, err := Encrypt(2, &p, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, p, gotPlaintext, err := Decrypt(nil, nil, key, salt, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Error("version was wrong:", version)
+	}
+	if p.Encoding != 1 {
+		t.Error("encoding was wrong:", p.Encoding)
+	}
+	if !bytes.Equal(plaintext, gotPlaintext) {
+		t.Errorf("want: %s, got: %s", plaintext, gotPlaintext)
+	}
+}
+
+func TestCryptCompression(t *testing.T) {
+	t.Parallel()
+
+	plaintext := bytes.Repeat([]byte(`{"kind":"set","key":"name","value":"x"}`), 100)
+
+	c, err := getVersion(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1 := bytes.Repeat([]byte{1}, c.keySize)
+	key2 := bytes.Repeat([]byte{2}, c.keySize)
+	salt1 := bytes.Repeat([]byte{3}, c.saltSize)
+	salt2 := bytes.Repeat([]byte{4}, c.saltSize)
+
+	single := Params{Keys: [][]byte{key1}, Salts: [][]byte{salt1}}
+
+	master, ivm, err := NewMasterKey(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkey1, iv1, err := EncryptMasterKey(3, key1, master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkey2, iv2, err := EncryptMasterKey(3, key2, master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user1 := sha256.Sum256([]byte("user1"))
+	user2 := sha256.Sum256([]byte("user2"))
+	multi := Params{
+		NUsers: 2,
+		Users:  [][]byte{user1[:], user2[:]},
+		Keys:   [][]byte{key1, nil},
+		Salts:  [][]byte{salt1, salt2},
+		IVs:    [][]byte{iv1, iv2},
+		MKeys:  [][]byte{mkey1, mkey2},
+		IVM:    ivm,
+		Master: master,
+	}
+
+	for name, p := range map[string]Params{"single": single, "multi": multi} {
+		uncompressed, err := Encrypt(3, &p, plaintext)
+		if err != nil {
+			t.Fatalf("%s) %v", name, err)
+		}
+
+		p.Compression = CompressionDeflate
+		if _, err := Encrypt(2, &p, plaintext); err == nil {
+			t.Errorf("%s) version 2 should not be able to record a compression", name)
+		}
+
+		ciphertext, err := Encrypt(3, &p, plaintext)
+		if err != nil {
+			t.Fatalf("%s) %v", name, err)
+		}
+		if len(ciphertext) >= len(uncompressed) {
+			t.Errorf("%s) should be smaller compressed: %d >= %d", name, len(ciphertext), len(uncompressed))
+		}
+
+		var user []byte
+		if p.NUsers != 0 {
+			user = []byte("user1")
+		}
+		_, got, gotPlaintext, err := Decrypt(user, nil, key1, salt1, ciphertext)
+		if err != nil {
+			t.Fatalf("%s) %v", name, err)
+		}
+		if got.Compression != CompressionDeflate {
+			t.Errorf("%s) compression was wrong: %d", name, got.Compression)
+		}
+		if !bytes.Equal(plaintext, gotPlaintext) {
+			t.Errorf("%s) want: %s, got: %s", name, plaintext, gotPlaintext)
+		}
+
+		// Encrypting again with what came out of Decrypt keeps it compressed
+		ciphertext, err = Encrypt(3, &got, plaintext)
+		if err != nil {
+			t.Fatalf("%s) %v", name, err)
+		}
+		_, _, gotPlaintext, err = Decrypt(user, nil, key1, salt1, ciphertext)
+		if err != nil {
+			t.Fatalf("%s) %v", name, err)
+		}
+		if !bytes.Equal(plaintext, gotPlaintext) {
+			t.Errorf("%s) want: %s, got: %s", name, plaintext, gotPlaintext)
+		}
+	}
+}
+
 func TestDecryptV0(t *testing.T) {
 	t.Parallel()
 
@@ -34,13 +34,33 @@ func newMasterKeyV1(c config) (master, ivm []byte, err error) {
 // or in the multi-user case:
 // 8:magic|4:version|4:nusers|32:u1|32:s1|32:iv1|80:(mk)|32:u2|32:s2|32:iv2|80:(mk)|32:ivm|(sha|pt)
 // where the sha512 covers all fields except itself
+//
+// Version 2 adds 4:encoding after nusers in both cases and version 3 adds
+// 4:compression after that, the sha512 is of the compressed plaintext.
 func encryptV1(c config, p *Params, plaintext []byte) (encrypted []byte, err error) {
+	plaintext, err = compress(p.Compression, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
 	if p.NUsers == 0 {
 		return encryptV1Single(c, p, plaintext)
 	}
 	return encryptV1Multi(c, p, plaintext)
 }
 
+// header returns the fixed part of the plaintext header
+func header(c config, p *Params, nUsers int) string {
+	h := fmt.Sprintf("%s%04d%04d", magicStr, c.version, nUsers)
+	if c.headerLen > magicLen {
+		h += fmt.Sprintf("%04d", p.Encoding)
+	}
+	if c.headerLen > magicLen+encodingLen {
+		h += fmt.Sprintf("%04d", p.Compression)
+	}
+	return h
+}
+
 func encryptV1Single(c config, p *Params, plaintext []byte) (encrypted []byte, err error) {
 	cipherSuite, err := cipherSuite(c)
 	if err != nil {
@@ -66,10 +86,10 @@ func encryptV1Single(c config, p *Params, plaintext []byte) (encrypted []byte, e
 		return nil, fmt.Errorf("failed to get randomness for iv: %w", err)
 	}
 
-	plaintextHeader := make([]byte, magicLen+c.saltSize+c.blockSize)
-	copy(plaintextHeader, fmt.Sprintf("%s%04d%04d", magicStr, c.version, 0))
-	copy(plaintextHeader[magicLen:], p.Salts[0])
-	copy(plaintextHeader[magicLen+c.saltSize:], iv)
+	plaintextHeader := make([]byte, c.headerLen+c.saltSize+c.blockSize)
+	copy(plaintextHeader, header(c, p, 0))
+	copy(plaintextHeader[c.headerLen:], p.Salts[0])
+	copy(plaintextHeader[c.headerLen+c.saltSize:], iv)
 
 	sha := sha512.New()
 	_, _ = sha.Write(plaintextHeader)
@@ -104,12 +124,12 @@ func encryptV1Multi(c config, p *Params, plaintext []byte) (encrypted []byte, er
 
 	userSize := sha256.Size + c.saltSize + c.blockSize + c.keySize
 	plaintextHeader := make([]byte,
-		magicLen+(userSize*p.NUsers)+c.blockSize,
+		c.headerLen+(userSize*p.NUsers)+c.blockSize,
 	)
-	copy(plaintextHeader, fmt.Sprintf("%s%04d%04d", magicStr, c.version, p.NUsers))
+	copy(plaintextHeader, header(c, p, p.NUsers))
 
 	// Copy all user data into the plaintext header
-	offset := magicLen
+	offset := c.headerLen
 	for i := 0; i < p.NUsers; i++ {
 		key := p.Keys[i]
 		if len(key) != 0 && len(key) != c.keySize {
@@ -219,10 +239,36 @@ func decryptV1(c config, user, passphrase, key, salt, encrypted []byte) (p Param
 		return p, nil, ErrNeedUser
 	}
 
+	var encoding, compression int
+	if c.headerLen > magicLen {
+		i, err := strconv.ParseInt(string(encrypted[magicLen:magicLen+encodingLen]), 10, 32)
+		if err != nil {
+			return p, nil, ErrInvalidFileFormat
+		}
+		encoding = int(i)
+	}
+	if c.headerLen > magicLen+encodingLen {
+		offset := magicLen + encodingLen
+		i, err := strconv.ParseInt(string(encrypted[offset:offset+compressionLen]), 10, 32)
+		if err != nil {
+			return p, nil, ErrInvalidFileFormat
+		}
+		compression = int(i)
+	}
+
 	if nUsers == 0 {
-		return decryptV1Single(c, passphrase, key, salt, encrypted)
+		p, plaintext, err = decryptV1Single(c, passphrase, key, salt, encrypted)
+	} else {
+		p, plaintext, err = decryptV1Multi(c, nUsers, user, passphrase, key, salt, encrypted)
 	}
-	return decryptV1Multi(c, nUsers, user, passphrase, key, salt, encrypted)
+	p.Encoding = encoding
+	p.Compression = compression
+	if err != nil {
+		return p, nil, err
+	}
+
+	plaintext, err = decompress(compression, plaintext)
+	return p, plaintext, err
 }
 
 func decryptV1Single(c config, passphrase, key, salt, encrypted []byte) (p Params, plaintext []byte, err error) {
@@ -232,7 +278,7 @@ func decryptV1Single(c config, passphrase, key, salt, encrypted []byte) (p Param
 	}
 
 	// Pull salt out and derive key
-	newSalt := encrypted[magicLen : magicLen+c.saltSize]
+	newSalt := encrypted[c.headerLen : c.headerLen+c.saltSize]
 	doDerive := !bytes.Equal(salt, newSalt)
 
 	if key == nil || doDerive {
@@ -253,10 +299,10 @@ func decryptV1Single(c config, passphrase, key, salt, encrypted []byte) (p Param
 	}
 
 	// Copy the ciphertext to where we can decode it
-	ciphertext := make([]byte, len(encrypted)-magicLen-c.saltSize-c.blockSize)
-	copy(ciphertext, encrypted[magicLen+c.saltSize+c.blockSize:])
+	ciphertext := make([]byte, len(encrypted)-c.headerLen-c.saltSize-c.blockSize)
+	copy(ciphertext, encrypted[c.headerLen+c.saltSize+c.blockSize:])
 
-	iv := encrypted[magicLen+c.saltSize : magicLen+c.saltSize+c.blockSize]
+	iv := encrypted[c.headerLen+c.saltSize : c.headerLen+c.saltSize+c.blockSize]
 	ivOffset := len(iv)
 	for i := len(ciphers) - 1; i >= 0; i-- {
 		c := ciphers[i]
@@ -281,7 +327,7 @@ func decryptV1Single(c config, passphrase, key, salt, encrypted []byte) (p Param
 
 	// Verify integrity
 	sha := sha512.New()
-	_, _ = sha.Write(encrypted[:magicLen])
+	_, _ = sha.Write(encrypted[:c.headerLen])
 	_, _ = sha.Write(salt)
 	_, _ = sha.Write(iv)
 	_, _ = sha.Write(plaintext)
@@ -313,7 +359,7 @@ func decryptV1Multi(c config, nUsers int, user, passphrase, key, salt, encrypted
 	s := sha256.Sum256(user)
 	userHash := s[:]
 
-	plaintextHeader := encrypted[magicLen:]
+	plaintextHeader := encrypted[c.headerLen:]
 
 	for i := 0; i < nUsers; i++ {
 		p.Users = append(p.Users, make([]byte, sha256.Size))
@@ -433,7 +479,7 @@ func decryptV1Multi(c config, nUsers int, user, passphrase, key, salt, encrypted
 	plaintext = ciphertext[sha512.Size:]
 
 	newHash := sha512.New()
-	_, _ = newHash.Write(encrypted[:magicLen+(userSize*p.NUsers)+c.blockSize])
+	_, _ = newHash.Write(encrypted[:c.headerLen+(userSize*p.NUsers)+c.blockSize])
 	_, _ = newHash.Write(plaintext)
 	shaSum := newHash.Sum(nil)
 
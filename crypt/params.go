@@ -17,6 +17,15 @@ type Params struct {
 	// encrypting the same version or not.
 	version int
 
+	// Encoding is how the plaintext is encoded, it's recorded in the header
+	// so that it can be decoded without guessing. The meaning of the number
+	// is up to the caller, version 1 can only record 0.
+	Encoding int
+	// Compression is how the plaintext is compressed before it's encrypted,
+	// one of the Compression constants. Versions before 3 can only record
+	// CompressionNone.
+	Compression int
+
 	// Users is how many users exist where User is which user is selected
 	// In a single-user file all of these will be 0
 	NUsers int
@@ -50,8 +59,23 @@ type Params struct {
 	Master []byte
 }
 
+// Version is the version of the file the params were decrypted from, 0 if
+// they didn't come from Decrypt
+func (p Params) Version() int {
+	return p.version
+}
+
 // validate the encryption params for encrypting
 func (p Params) validate(c config) error {
+	if p.Encoding < 0 || p.Encoding > maxEncoding {
+		return fmt.Errorf("encoding must be 0 <= encoding <= %d", maxEncoding)
+	}
+	if p.Encoding != 0 && c.headerLen == magicLen {
+		return fmt.Errorf("version %d can't record an encoding", c.version)
+	}
+	if p.Compression != CompressionNone && c.headerLen <= magicLen+encodingLen {
+		return fmt.Errorf("version %d can't record a compression", c.version)
+	}
 	if len(p.Keys) == 0 {
 		return errors.New("must have at least one key")
 	}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/aarondl/bpass/blobformat"
+	"github.com/aarondl/bpass/txlogs"
+
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
@@ -17,6 +20,9 @@ func export(u *uiContext) error {
 	for entry, blob := range u.store.DB.Snapshot {
 		entries = append(entries, entry)
 		for k := range blob {
+			if txlogs.IsItemKey(k) {
+				continue
+			}
 			keysSet[k] = struct{}{}
 		}
 	}
@@ -44,6 +50,9 @@ func export(u *uiContext) error {
 		record := make([]string, len(keys))
 		for i, key := range keys {
 			record[i] = blob[key]
+			if !flagExportReveal && blobformat.Blob(blob).IsSecret(key) {
+				record[i] = maskValue(record[i])
+			}
 		}
 
 		out.Write(record)
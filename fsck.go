@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/aarondl/bpass/txlogs"
+)
+
+// fsckExplanations say what a problem means and what repairing it does
+var fsckExplanations = map[txlogs.ProblemKind]string{
+	txlogs.ProblemOrphan: "a change to an entry that no longer exists, it " +
+		"can't be applied. Repair removes it, the data is the same without it.",
+	txlogs.ProblemDuplicateAdd: "an entry is added twice, the second can't be " +
+		"applied. Repair removes it, the data is the same without it.",
+	txlogs.ProblemTimeOrder: "a change was made on a computer with a wrong " +
+		"clock. This is harmless, the order of changes doesn't depend on it.",
+	txlogs.ProblemStaleSnapshot: "the saved copy of the current data doesn't " +
+		"match the history so the wrong data is shown. Repair rebuilds it " +
+		"from the history.",
+	txlogs.ProblemInvalid: "a change can't be applied. This can't be " +
+		"repaired automatically, restore the file from a copy that syncs with it.",
+}
+
+// fsck checks the file for problems and offers to repair them. A backup of
+// the file is made before anything is repaired.
+func fsck(u *uiContext) error {
+	if err := u.store.Verify(); err != nil {
+		if !txlogs.IsBrokenChain(err) {
+			return err
+		}
+		errColor.Println("history has been tampered with or corrupted:", err)
+		errColor.Println("this can't be repaired, restore the file from a copy that syncs with it")
+	}
+
+	problems := u.store.Check()
+	repairable := 0
+	for _, p := range problems {
+		if p.Repairable() {
+			repairable++
+		}
+		errColor.Println(p)
+		fmt.Fprintf(u.out, "  %s\n", fsckExplanations[p.Kind])
+	}
+
+	if repairable != 0 && !u.readOnly {
+		yes, err := u.getYesNo(fmt.Sprintf("repair %d problems?", repairable))
+		if err != nil {
+			return err
+		}
+		if yes {
+			if err = u.backupFile(); err != nil {
+				return err
+			}
+			repaired := u.store.Repair(problems)
+			infoColor.Printf("repaired %d problems\n", repaired)
+			// A stale snapshot is repaired without changing the log
+			u.changed = u.changed || repaired != 0
+		}
+	}
+
+	// Names can only be checked once the log can be replayed
+	if err := u.store.UpdateSnapshot(); err != nil {
+		errColor.Println("cannot check entries until the problems above are repaired")
+		return nil
+	}
+
+	unnamed, err := u.store.Unnamed()
+	if err != nil {
+		return err
+	}
+	for _, uuid := range unnamed {
+		errColor.Printf("entry %s has no name\n", uuid)
+		fmt.Fprintln(u.out, "  it can't be found by any command. Repair names it after its uuid.")
+	}
+
+	if len(unnamed) != 0 && !u.readOnly {
+		yes, err := u.getYesNo(fmt.Sprintf("repair %d entries?", len(unnamed)))
+		if err != nil {
+			return err
+		}
+		if yes {
+			for _, uuid := range unnamed {
+				name := "unnamed-" + uuid
+				if err = u.store.Rename(uuid, name); err != nil {
+					return err
+				}
+				infoColor.Printf("named %s %q\n", uuid, name)
+			}
+		}
+	}
+
+	if len(problems) == 0 && len(unnamed) == 0 {
+		infoColor.Printf("no problems found in %d transactions\n", len(u.store.Log))
+	}
+	return nil
+}
+
+// backupFile copies the file as it is on disk next to it
+func (u *uiContext) backupFile() error {
+	data, err := ioutil.ReadFile(u.filename)
+	if err != nil {
+		return fmt.Errorf("failed to read the file to back it up: %w", err)
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", u.filename, time.Now().Format("20060102150405"))
+	if err = ioutil.WriteFile(backup, data, 0600); err != nil {
+		return fmt.Errorf("failed to back up the file: %w", err)
+	}
+
+	infoColor.Println("backed up the file to:", backup)
+	infoColor.Println("copy it back over the file to undo the repairs")
+	return nil
+}
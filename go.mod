@@ -11,6 +11,7 @@ require (
 	github.com/integrii/flaggy v1.5.2
 	github.com/mattn/go-colorable v0.1.13
 	github.com/pquerna/otp v1.3.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	golang.org/x/crypto v0.0.0-20221010152910-d6f0a8c073c2
 	golang.org/x/exp v0.0.0-20221006183845-316c7553db56
 	golang.org/x/sys v0.0.0-20221010170243-090e33056c14
@@ -20,4 +21,5 @@ require (
 	github.com/boombuler/barcode v1.0.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/mattn/go-isatty v0.0.16 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 )
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarondl/bpass/blobformat"
+	"github.com/aarondl/bpass/txlogs"
+)
+
+var compactBlurb = `WARNING: This will permanently erase all history before the cutoff, it
+cannot be viewed with show/--time afterwards. Copies of this file that are
+compacted at a different point with unsynced changes before the cutoff will
+no longer share history with this one.
+`
+
+var purgeBlurb = `WARNING: This will permanently erase the old values of %s from the
+history of %q, they cannot be viewed with show/--time afterwards. Copies of
+this file will have them erased as well when they are synced.
+`
+
+func (u *uiContext) compact(before time.Time) error {
+	compacted := &txlogs.DB{Log: make([]txlogs.Tx, len(u.store.Log)), Codec: u.store.Codec}
+	copy(compacted.Log, u.store.Log)
+
+	folded, err := compacted.Compact(before)
+	if err != nil {
+		return err
+	}
+	if folded == 0 {
+		infoColor.Println("nothing to compact before", before.Format(dateLayout))
+		return nil
+	}
+
+	oldSize, err := savedSize(u.store.DB)
+	if err != nil {
+		return err
+	}
+	newSize, err := savedSize(compacted)
+	if err != nil {
+		return err
+	}
+
+	infoColor.Printf("compacting %d of %d transactions before %s\n",
+		folded, len(u.store.Log), before.Format(dateLayout))
+	infoColor.Printf("size: %s => %s (saves %s)\n",
+		byteSize(oldSize), byteSize(newSize), byteSize(oldSize-newSize))
+
+	errColor.Println(compactBlurb)
+	yes, err := u.getYesNo("are you sure you wish to proceed?")
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return nil
+	}
+
+	if _, err = u.store.Compact(before); err != nil {
+		return err
+	}
+	if err = u.store.UpdateSnapshot(); err != nil {
+		return err
+	}
+
+	infoColor.Println("compacted history before", before.Format(dateLayout))
+	return nil
+}
+
+// purge erases old values of a key (or all keys) from an entry's history
+func (u *uiContext) purge(search, key string) error {
+	uuid, err := u.findOne(search)
+	if err != nil {
+		return err
+	}
+	if len(uuid) == 0 {
+		return nil
+	}
+
+	blob, err := u.store.MustFind(uuid)
+	if err != nil {
+		return err
+	}
+
+	what := "all keys"
+	if len(key) != 0 {
+		what = fmt.Sprintf("%q", key)
+	}
+
+	errColor.Printf(purgeBlurb, what, blob.Name())
+	yes, err := u.getYesNo("are you sure you wish to proceed?")
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return nil
+	}
+
+	purged, err := u.store.Purge(uuid, key)
+	if err != nil {
+		return err
+	}
+
+	infoColor.Printf("purged %d old values from %s\n", purged, blob.Name())
+	return nil
+}
+
+// revert restores an entry to how it was at a snapshot (see show)
+func (u *uiContext) revert(search string, snapshot int) error {
+	uuid, err := u.findOne(search)
+	if err != nil {
+		return err
+	}
+	if len(uuid) == 0 {
+		return nil
+	}
+
+	blob, err := u.store.MustFind(uuid)
+	if err != nil {
+		return err
+	}
+
+	if snaps := u.store.NVersions(uuid); snapshot < 1 || snapshot > snaps {
+		errColor.Printf("%s only has %d snapshots\n", blob.Name(), snaps)
+		return nil
+	}
+
+	changes, err := u.store.Revert(uuid, snapshot)
+	if err == blobformat.ErrNameNotUnique {
+		errColor.Println("another entry has the name it had, rename it first")
+		return nil
+	} else if err != nil {
+		errColor.Println(err)
+		return nil
+	}
+
+	if changes == 0 {
+		infoColor.Println("no changes")
+		return nil
+	}
+
+	infoColor.Printf("reverted %s to snapshot %d (%d changes)\n", blob.Name(), snapshot, changes)
+	return nil
+}
+
+// blame shows who last changed each key of an entry and when
+func (u *uiContext) blame(search string) error {
+	uuid, err := u.findOne(search)
+	if err != nil {
+		return err
+	}
+	if len(uuid) == 0 {
+		return nil
+	}
+
+	blames, err := u.store.Blame(uuid)
+	if err != nil {
+		return err
+	}
+
+	keyWidth, userWidth := 0, 1
+	for _, b := range blames {
+		if b.Key == blobformat.KeyUpdated {
+			continue
+		}
+		if len(b.Key) > keyWidth {
+			keyWidth = len(b.Key)
+		}
+		if len(b.User) > userWidth {
+			userWidth = len(b.User)
+		}
+	}
+
+	for _, b := range blames {
+		// This changes every time anything else does
+		if b.Key == blobformat.KeyUpdated {
+			continue
+		}
+
+		user := b.User
+		if len(user) == 0 {
+			user = "-"
+		}
+		fmt.Fprintf(u.out, "%s %-*s %s\n",
+			keyColor.Sprintf("%-*s", keyWidth+1, b.Key+":"),
+			userWidth, user,
+			b.Time.Format(historyLayout),
+		)
+	}
+
+	return nil
+}
+
+// keyHistory lists the distinct values a key of an entry has had, newest
+// first and numbered so one can be copied with cp --history. Secret values
+// are masked unless reveal is true.
+func (u *uiContext) keyHistory(search, key string, reveal bool) error {
+	uuid, err := u.findOne(search)
+	if err != nil {
+		return err
+	}
+	if len(uuid) == 0 {
+		return nil
+	}
+
+	blob, err := u.store.MustFind(uuid)
+	if err != nil {
+		return err
+	}
+
+	values, err := u.store.Values(uuid, key)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		infoColor.Printf("%s has never been set on %s\n", key, blob.Name())
+		return nil
+	}
+
+	current, hasCurrent := blob[key]
+	secret := !reveal && blob.IsSecret(key)
+
+	userWidth := 1
+	for _, v := range values {
+		if len(v.User) > userWidth {
+			userWidth = len(v.User)
+		}
+	}
+
+	for i, v := range values {
+		user := v.User
+		if len(user) == 0 {
+			user = "-"
+		}
+		value := v.Value
+		if secret {
+			value = maskValue(value)
+		}
+		// Keep multi-line values (like notes) on one line
+		value = strings.ReplaceAll(value, "\n", `\n`)
+		if i == 0 && hasCurrent && current == v.Value {
+			value += infoColor.Sprint(" (current)")
+		}
+
+		fmt.Fprintf(u.out, "%s %s %-*s %s\n",
+			keyColor.Sprintf("%3d)", i+1),
+			v.Time.Format(historyLayout),
+			userWidth, user,
+			value,
+		)
+	}
+
+	return nil
+}
+
+// at views the file as it was at a time or version, or returns to the
+// present if there are no arguments
+func (u *uiContext) at(args []string) error {
+	if len(args) == 0 {
+		if u.present == nil {
+			infoColor.Println("already viewing the present")
+			return nil
+		}
+
+		u.returnToPresent()
+		infoColor.Println("returned to the present")
+		return nil
+	}
+
+	db := u.store.DB
+	if u.present != nil {
+		db = u.present
+	}
+
+	view, err := viewOf(db, strings.Join(args, " "))
+	if err != nil {
+		errColor.Println(err)
+		return nil
+	}
+
+	u.viewAt(view)
+
+	when := "the beginning"
+	if !view.Time().IsZero() {
+		when = view.Time().Format(historyLayout)
+	}
+	infoColor.Printf("viewing version %d of %d (%s) read-only, use \"at\" to return\n",
+		view.Version(), len(db.Log), when)
+	return nil
+}
+
+// pointLayouts are the formats a time in history can be given in, the
+// ones without a space are for commands that split their arguments on them
+var pointLayouts = []string{
+	historyLayout,
+	dateLayout,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// isPoint is true if s is a version or a time that viewOf can parse
+func isPoint(s string) bool {
+	if _, err := strconv.Atoi(s); err == nil {
+		return true
+	}
+	for _, layout := range pointLayouts {
+		if _, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// viewOf parses a version or a time and returns the view of the db at it
+func viewOf(db *txlogs.DB, when string) (*txlogs.View, error) {
+	if version, err := strconv.Atoi(when); err == nil {
+		return db.AtVersion(version)
+	}
+
+	for _, layout := range pointLayouts {
+		if t, err := time.ParseInLocation(layout, when, time.Local); err == nil {
+			return db.At(t)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to parse %q, use a version or a time in the format: %s",
+		when, strings.Join(pointLayouts, " or "))
+}
+
+// diff shows what changed in entries matching search (all if empty) between
+// two points in history, to defaults to the present.
+func (u *uiContext) diff(search, from, to string, reveal bool) error {
+	db := u.store.DB
+	if u.present != nil {
+		db = u.present
+	}
+
+	before, err := viewOf(db, from)
+	if err != nil {
+		errColor.Println(err)
+		return nil
+	}
+	after, err := db.AtVersion(len(db.Log))
+	if len(to) != 0 {
+		after, err = viewOf(db, to)
+	}
+	if err != nil {
+		errColor.Println(err)
+		return nil
+	}
+
+	// Entries may only exist on one side so both are searched
+	beforeStore := blobformat.Blobs{DB: before.DB()}
+	afterStore := blobformat.Blobs{DB: after.DB()}
+	beforeMatches, err := beforeStore.Search(search)
+	if err != nil {
+		return err
+	}
+	afterMatches, err := afterStore.Search(search)
+	if err != nil {
+		return err
+	}
+
+	shown := 0
+	for _, diff := range before.Diff(after) {
+		name, ok := afterMatches[diff.UUID]
+		if !ok {
+			if name, ok = beforeMatches[diff.UUID]; !ok {
+				continue
+			}
+		}
+
+		var keys []txlogs.KeyDiff
+		for _, k := range diff.Keys {
+			// This changes every time anything else does
+			if k.Key != blobformat.KeyUpdated {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 && diff.Kind == txlogs.DiffModified {
+			continue
+		}
+
+		shown++
+		switch diff.Kind {
+		case txlogs.DiffAdded:
+			infoColor.Println("+", name)
+		case txlogs.DiffRemoved:
+			errColor.Println("-", name)
+		default:
+			fmt.Fprintln(u.out, "~", name)
+		}
+
+		// A key is secret if it is on either side
+		beforeBlob, err := beforeStore.Find(diff.UUID)
+		if err != nil {
+			return err
+		}
+		afterBlob, err := afterStore.Find(diff.UUID)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			secret := !reveal && (beforeBlob.IsSecret(k.Key) || afterBlob.IsSecret(k.Key))
+			old := diffValue(k.Old, secret)
+			now := diffValue(k.New, secret)
+			switch k.Kind {
+			case txlogs.DiffAdded:
+				fmt.Fprintf(u.out, "    + %s %s\n", keyColor.Sprint(k.Key+":"), now)
+			case txlogs.DiffRemoved:
+				fmt.Fprintf(u.out, "    - %s %s\n", keyColor.Sprint(k.Key+":"), old)
+			default:
+				fmt.Fprintf(u.out, "    ~ %s %s => %s\n", keyColor.Sprint(k.Key+":"), old, now)
+			}
+		}
+	}
+
+	if shown == 0 {
+		infoColor.Println("no changes")
+	}
+	return nil
+}
+
+// diffValue masks secret values, multi-line values are put on one line.
+func diffValue(value string, secret bool) string {
+	if secret && len(value) != 0 {
+		return "********"
+	}
+	return strconv.Quote(value)
+}
+
+// viewAt switches the store to a view of the past, the present is kept to
+// return to
+func (u *uiContext) viewAt(view *txlogs.View) {
+	if u.present == nil {
+		u.present = u.store.DB
+	}
+	u.store = blobformat.Blobs{DB: view.DB()}
+}
+
+// returnToPresent undoes viewAt
+func (u *uiContext) returnToPresent() {
+	if u.present == nil {
+		return
+	}
+
+	u.store = blobformat.Blobs{DB: u.present}
+	u.present = nil
+}
+
+// savedSize is the size of the plaintext we'd write to disk
+func savedSize(db *txlogs.DB) (int, error) {
+	if err := db.UpdateSnapshot(); err != nil {
+		return 0, err
+	}
+
+	b, err := db.Save()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// verify checks the hash chain of the log and reports the first broken link
+func (u *uiContext) verify() error {
+	err := u.store.Verify()
+	if err == nil {
+		infoColor.Printf("verified %d transactions\n", len(u.store.Log))
+		return nil
+	}
+	if !txlogs.IsBrokenChain(err) {
+		return err
+	}
+
+	broken := err.(txlogs.BrokenChain)
+	errColor.Printf("history is broken at transaction %d of %d: %s\n",
+		broken.Index+1, len(u.store.Log), broken.Reason)
+	errColor.Printf("%s %s %s %s\n",
+		time.Unix(0, broken.Tx.Time).Format(historyLayout),
+		broken.Tx.Kind, broken.Tx.UUID, broken.Tx.Key)
+	return nil
+}
@@ -102,8 +102,8 @@ func importLastpass(u *uiContext) error {
 		if record[6] == "1" {
 			labels = append(labels, "lpfav")
 		}
-		if len(labels) != 0 {
-			u.store.DB.Set(uuid, blobformat.KeyLabels, strings.Join(labels, ","))
+		for _, label := range labels {
+			u.store.DB.AddItem(uuid, blobformat.KeyLabels, label)
 		}
 	}
 
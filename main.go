@@ -18,8 +18,10 @@ import (
 )
 
 var (
-	version      = "unknown"
-	cryptVersion = 1
+	version = "unknown"
+	// keyVersion is the crypt version used to derive keys and make master
+	// keys, the version the file is saved as is from saveVersion
+	keyVersion = 3
 )
 
 func main() {
@@ -89,6 +91,27 @@ func main() {
 			fmt.Printf("error occurred: %+v\nexiting without saving", err)
 			goto Exit
 		}
+	case logCmd.Used:
+		if err = logSubcommand(ctx); err != nil {
+			fmt.Printf("error occurred: %+v\nexiting without saving", err)
+		}
+		// Looking at the history never changes the file
+		goto Exit
+	case fsckCmd.Used:
+		if err = fsck(ctx); err != nil {
+			fmt.Printf("error occurred: %+v\nexiting without saving", err)
+			goto Exit
+		}
+		// Only repairs are saved
+		if !ctx.changed {
+			goto Exit
+		}
+	case auditCmd.Used:
+		if err = auditSubcommand(ctx); err != nil {
+			fmt.Printf("error occurred: %+v\nexiting without saving", err)
+		}
+		// The report never changes the file
+		goto Exit
 	default:
 		if !ctx.readOnly && !flagNoAutoSync {
 			if err = ctx.sync("", true, true); err != nil {
@@ -97,6 +120,8 @@ func main() {
 			}
 		}
 
+		ctx.warnOverdue()
+
 		if err = r.run(); err != nil {
 			if err == ErrInterrupt {
 				fmt.Println("exiting, did not save file")
@@ -106,8 +131,10 @@ func main() {
 			goto Exit
 		}
 
-		wrote := ctx.startTx != len(ctx.store.DB.Log)
-		if wrote && !ctx.readOnly && !flagNoAutoSync {
+		// Never save a view of the past over the file
+		ctx.returnToPresent()
+
+		if ctx.changed && !ctx.readOnly && !flagNoAutoSync {
 			if err = ctx.sync("", true, true); err != nil {
 				fmt.Println("failed to synchronize:", err)
 				goto Exit
@@ -175,7 +202,7 @@ func (u *uiContext) loadBlob() error {
 		}
 
 		// Derive a new key from the password for later encryption
-		key, salt, err := crypt.DeriveKey(cryptVersion, []byte(pwd))
+		key, salt, err := crypt.DeriveKey(keyVersion, []byte(pwd))
 		if err != nil {
 			return err
 		}
@@ -212,12 +239,14 @@ func (u *uiContext) loadBlob() error {
 
 		u.user = user
 		u.pass = pwd
+		u.version = params.Version()
+		u.compression = params.Compression
 		u.key = params.Keys[params.User]
 		u.salt = params.Salts[params.User]
 		u.master = params.Master
 		u.ivm = params.IVM
 
-		store, err := txlogs.New(pt)
+		store, err := txlogs.NewCodec(pt, fileCodec(params, pt))
 		if err != nil {
 			return err
 		}
@@ -230,21 +259,25 @@ func (u *uiContext) loadBlob() error {
 		u.store = blobformat.Blobs{DB: new(txlogs.DB)}
 	} else if u.readOnly {
 		infoColor.Println("opened file in read-only mode at:", historyTime.Format("January 02, 2006 - 15:04:05"))
-		u.store.DB.ResetSnapshot()
-		historyUnix := historyTime.UnixNano()
-		for i, tx := range u.store.DB.Log {
-			if tx.Time > historyUnix {
-				u.store.DB.Log = u.store.DB.Log[0:i]
-				break
-			}
+		view, err := u.store.At(historyTime)
+		if err != nil {
+			return err
 		}
-		if err := u.store.DB.UpdateSnapshot(); err != nil {
+		u.viewAt(view)
+	}
+
+	if len(flagCodec) != 0 && !u.readOnly {
+		if u.store.Codec, err = txlogs.ParseCodec(flagCodec); err != nil {
 			return err
 		}
 	}
 
-	// Save this to know if we've actually edited the database in some way
-	u.startTx = len(u.store.DB.Log)
+	db := u.store.DB
+	if u.present != nil {
+		db = u.present
+	}
+	u.watchChanges(db)
+	u.store.User = u.user
 
 	return nil
 }
@@ -264,7 +297,7 @@ func (u *uiContext) saveBlob() error {
 		return err
 	}
 
-	data, err = crypt.Encrypt(cryptVersion, params, data)
+	data, err = crypt.Encrypt(u.saveVersion(), params, data)
 	if err != nil {
 		return err
 	}
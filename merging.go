@@ -83,12 +83,12 @@ func mergeBlobs(u *uiContext, remotes []blobParts) (m mergeResult, err error) {
 				// Either the salt has changed or the password has changed, either
 				// way we'll try to determine who has the latest updates in the log
 				// to see which credential set we should keep.
-				lastTimeLocal := m.Log[len(m.Log)-1].Time
-				lastTimeRemote := r.Log[len(r.Log)-1].Time
+				lastIDLocal := m.Log[len(m.Log)-1].ID
+				lastIDRemote := r.Log[len(r.Log)-1].ID
 
-				if lastTimeLocal < lastTimeRemote {
+				if lastIDLocal < lastIDRemote {
 					takeRemoteCreds = true
-				} else if lastTimeLocal == lastTimeRemote {
+				} else if lastIDLocal == lastIDRemote {
 					infoColor.Printf("remote %q has different credentials!\n", r.Name)
 					takeRemoteCreds, err = u.getYesNo("use remote credentials from now on?")
 					if err != nil {
@@ -214,6 +214,8 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 
 	var c []txlogs.Tx
 	var conflicts []txlogs.Conflict
+	var secrets map[string]blobformat.Blob
+	var secretsErr error
 	for {
 		c, conflicts = txlogs.Merge(local, remote, conflicts)
 
@@ -223,8 +225,26 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 
 		infoColor.Println(len(conflicts), "conflicts occurred during syncing!")
 
+		// The local log doesn't change while conflicts are resolved so
+		// what's secret is only worked out once
+		if secrets == nil && secretsErr == nil {
+			_, secrets, secretsErr = secretTxs(u.store.DB)
+			if secretsErr != nil {
+				secrets = nil
+			}
+		}
+
 		for i, c := range conflicts {
 			switch c.Kind {
+			case txlogs.ConflictKindBrokenChain:
+				side, tx := "local", c.Initial
+				if len(c.Conflict.Kind) != 0 {
+					side, tx = "remote", c.Conflict
+				}
+				errColor.Printf("the history of the %s file has been tampered with or corrupted at: %s\n",
+					side, time.Unix(0, tx.Time).Format(time.RFC3339))
+				errColor.Println(`run "verify" on each copy to find the bad one and restore it from a backup`)
+				return nil, errors.New("refusing to merge a broken history")
 			case txlogs.ConflictKindRoot:
 				errColor.Println(syncNoCommonAncestryWarning)
 				yes, err := u.getYesNo("do you want to merge these anyway?")
@@ -239,16 +259,16 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 				conflicts[i].Force()
 			case txlogs.ConflictKindDeleteSet:
 				infoColor.Printf("entry %q was deleted at: %s\nbut at %s, ",
-					c.Initial.UUID,
+					u.conflictName(c.Initial.UUID, local, remote),
 					time.Unix(0, c.Initial.Time).Format(time.RFC3339),
 					time.Unix(0, c.Conflict.Time).Format(time.RFC3339),
 				)
 
-				switch c.Initial.Kind {
+				switch c.Conflict.Kind {
 				case txlogs.TxSetKey:
 					infoColor.Printf("a set happened:\n%s = %s\n",
 						c.Conflict.Key,
-						c.Conflict.Value,
+						maskSecret(secrets, c.Conflict.UUID, c.Conflict.Key, c.Conflict.Value),
 					)
 				case txlogs.TxDeleteKey:
 					infoColor.Printf("a delete happened for key:\n%s\n",
@@ -256,6 +276,7 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 					)
 				}
 
+			DeleteSet:
 				for {
 					line, err := u.prompt(promptColor.Sprint("[R]estore item? [D]elete item? (r/R/d/D): "))
 					if err != nil {
@@ -265,10 +286,40 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 					switch line {
 					case "R", "r":
 						conflicts[i].DiscardInitial()
+						break DeleteSet
 					case "D", "d":
 						conflicts[i].DiscardConflict()
-					default:
-						continue
+						break DeleteSet
+					}
+				}
+			case txlogs.ConflictKindSetSet:
+				infoColor.Printf("entry %q key %q was changed on both sides:\n",
+					u.conflictName(c.Initial.UUID, local, remote),
+					c.Initial.Key,
+				)
+				infoColor.Printf(" local (%s): %s\n",
+					time.Unix(0, c.Initial.Time).Format(time.RFC3339),
+					maskSecret(secrets, c.Initial.UUID, c.Initial.Key, c.Initial.Value),
+				)
+				infoColor.Printf("remote (%s): %s\n",
+					time.Unix(0, c.Conflict.Time).Format(time.RFC3339),
+					maskSecret(secrets, c.Conflict.UUID, c.Conflict.Key, c.Conflict.Value),
+				)
+
+			SetSet:
+				for {
+					line, err := u.prompt(promptColor.Sprint("Keep [L]ocal? Keep [R]emote? (l/L/r/R): "))
+					if err != nil {
+						return nil, err
+					}
+
+					switch line {
+					case "L", "l":
+						conflicts[i].DiscardConflict()
+						break SetSet
+					case "R", "r":
+						conflicts[i].DiscardInitial()
+						break SetSet
 					}
 				}
 			}
@@ -277,3 +328,35 @@ func mergeLogs(u *uiContext, local []txlogs.Tx, remote []txlogs.Tx) ([]txlogs.Tx
 
 	return c, nil
 }
+
+// conflictName finds the name of an entry in a conflict, the entry may have
+// been deleted locally so the logs being merged are searched for the last
+// name it had if it's not in the local snapshot. The uuid is returned if it
+// never had a name.
+func (u *uiContext) conflictName(uuid string, logs ...[]txlogs.Tx) string {
+	if name := u.store.DB.Snapshot[uuid][blobformat.KeyName]; len(name) != 0 {
+		return name
+	}
+
+	for _, log := range logs {
+		for i := len(log) - 1; i >= 0; i-- {
+			tx := log[i]
+			if tx.Kind == txlogs.TxSetKey && tx.UUID == uuid && tx.Key == blobformat.KeyName && len(tx.Value) != 0 {
+				return tx.Value
+			}
+		}
+	}
+
+	return uuid
+}
+
+// maskSecret hides the value of secret keys the same way show does. last
+// has the entries as they last were (see secretTxs) so entries that were
+// deleted locally are checked as they were before the delete. If last is nil
+// because that couldn't be worked out the value is hidden.
+func maskSecret(last map[string]blobformat.Blob, uuid, key, value string) string {
+	if last == nil || last[uuid].IsSecret(key) {
+		return hideColor.Sprint(value)
+	}
+	return value
+}
@@ -138,6 +138,8 @@ func readlineAutocompleter(entryCompleter func(string) []string) readline.AutoCo
 		readline.PcItem("add"),
 		readline.PcItem("rm", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("mv", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("trash"),
+		readline.PcItem("undelete"),
 		readline.PcItem("ls"),
 		readline.PcItem("cd", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("labels"),
@@ -190,6 +192,7 @@ func readlineAutocompleter(entryCompleter func(string) []string) readline.AutoCo
 		),
 		readline.PcItem("label", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("rmlabel", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("rmsecret", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("pass", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("user", readline.PcItemDynamic(entryCompleter)),
 		readline.PcItem("email", readline.PcItemDynamic(entryCompleter)),
@@ -198,6 +201,19 @@ func readlineAutocompleter(entryCompleter func(string) []string) readline.AutoCo
 		readline.PcItem("addsync"),
 		readline.PcItem("adduser"),
 		readline.PcItem("rekey"),
+		readline.PcItem("compact"),
+		readline.PcItem("purge", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("verify"),
+		readline.PcItem("audit"),
+		readline.PcItem("at"),
+		readline.PcItem("undo"),
+		readline.PcItem("redo"),
+		readline.PcItem("diff", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("revert", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("log", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("blame", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("history", readline.PcItemDynamic(entryCompleter)),
+		readline.PcItem("due"),
 	)
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aarondl/bpass/blobformat"
 	"github.com/aarondl/color"
@@ -18,27 +19,38 @@ the entry name as a query in the key commands below.
 General Commands:
  passwd       - Change the file's password for current user
  help [topic] - This help (how did you find this without seeing this help?)
+ at [when]    - View the file as it was at a time (YYYY-MM-DD [HH:MM:SS]) or version, omit to return
+ undo [n]     - Undo the last n commands that changed the file (default 1)
+ redo [n]     - Redo the last n commands that were undone (default 1)
  exit         - Exit the repl
 
 Entry Commands (manage entries in the file):
- add <name>      - Add a new entry
- rm  <name>      - Delete an entry
- mv  <old> <new> - Rename an entry
- ls  [query]     - Lists entries, query restricts entries to a fuzzy match
- cd  [query]     - "cd" into an entry, omit argument to return to root
- labels <lbl...> - List entries by labels (entry must have all given labels)
+ add <name> [type] - Add a new entry (login, note, card, sshkey or identity)
+ rm  <name>        - Delete an entry
+ trash             - List deleted entries
+ undelete <name>   - Restore a deleted entry (by name or uuid from trash)
+ mv  <old> <new>   - Rename an entry
+ ls  [query]       - Lists entries, query restricts entries to a fuzzy match
+ cd  [query]       - "cd" into an entry, omit argument to return to root
+ labels <lbl...>   - List entries by labels (entry must have all given labels)
+ due [days]        - List entries that are overdue or due within days (default 14) for a password change
 
 Key commands (manage keys in entries, use "cd" command to omit query from these commands):
- show <query> [snapshot]    - Show all keys for an entry (optionally at a specific snapshot)
- set  <query> <key> [value] - Set a value on an entry (omit value for multi-line or password gen)
- get  <query> <key>         - Show a specific key of an entry
- cp   <query> <key>         - Copy a specific key of an entry to the clipboard
+ show <query> [snapshot]    - Show all keys for an entry (optionally at a specific snapshot), --reveal to show secrets
+ revert <query> <snapshot>  - Restore an entry to how it was at a snapshot
+ blame  <query>             - Show who last changed each key of an entry and when
+ history <query> [key]      - List the past values of a key (default pass), --reveal to show secrets
+ diff [query] <from> [to]   - Show changes since a version or time (YYYY-MM-DD[THH:MM]) and up to another, --reveal to show secrets
+ set  <query> <key> [value] - Set a value on an entry (omit value for multi-line or password gen), --secret to mask it, -- before a value ending in --secret
+ get  <query> <key> [index] - Show a specific key of an entry, --history <n> for value n from history
+ cp   <query> <key> [index] - Copy a specific key of an entry to the clipboard, --history <n> as in get
  edit <query> <key>         - Open $EDITOR to edit an existing value
  open <query>               - Launch browser using value in url key
  rmk  <query> <key>         - Delete a key from an entry
 
  label   <query>            - Add labels in an easier way than with set
  rmlabel <query> <label>    - Remove labels in an easier way than with edit
+ rmsecret <query> <key>     - Stop masking a key that was set with --secret
 
 Clipboard copy shortcuts (alias of cp <query> <key>):
  pass  <query>       - Copy password to clipboard
@@ -103,8 +115,15 @@ User/Password Commands:
 `
 
 var otherHelp = `Debug commands:
- dump <query>      - Dumps an entire entry in debug mode
- dumpall           - Dumps the entire store in debug mode
+ dump <query>        - Dumps an entire entry in debug mode, --reveal to show secrets
+ dumpall             - Dumps the entire store in debug mode, --reveal to show secrets
+
+Maintenance commands:
+ compact <date>      - Erase history before date (YYYY-MM-DD) to shrink the file
+ purge <query> [key] - Erase old values of key (or all keys) from an entry's history
+ verify              - Check that the history has not been tampered with
+ audit [days]        - Find reused, weak and old (default 365 days) passwords, missing totp and http urls
+ log [query] [opts]  - List changes, opts: --since <t> --until <t> --kind <k,...> --user <u> --json
 `
 
 const (
@@ -166,8 +185,22 @@ func (r *repl) run() error {
 			errColor.Println("cannot use write commands in read-only mode")
 			continue
 		}
+		if r.ctx.present != nil && !replCommand.ReadOnly {
+			errColor.Println(`cannot use write commands while viewing the past, use "at" to return`)
+			continue
+		}
 
-		err = replCommand.Run(r, cmd, args)
+		switch {
+		case replCommand.ReadOnly || replCommand.Undo == undoSelf:
+			err = replCommand.Run(r, cmd, args)
+		case replCommand.Undo == undoClear:
+			r.ctx.forgetUndo()
+			err = replCommand.Run(r, cmd, args)
+		default:
+			err = r.ctx.record(line, func() error {
+				return replCommand.Run(r, cmd, args)
+			})
+		}
 		if err == errExit {
 			return nil
 		} else if err != nil {
@@ -180,11 +213,13 @@ func (r *repl) run() error {
 
 type replCmd struct {
 	ReadOnly bool
+	Undo     undoKind
 	Run      func(r *repl, cmd string, args []string) error
 }
 
 var replCmds = map[string]replCmd{
 	"passwd": {
+		Undo: undoClear,
 		Run: func(r *repl, cmd string, args []string) error {
 			var user string
 			if len(args) > 0 {
@@ -196,6 +231,7 @@ var replCmds = map[string]replCmd{
 	},
 
 	"adduser": {
+		Undo: undoClear,
 		Run: func(r *repl, _ string, args []string) error {
 			if len(args) == 0 {
 				errColor.Println("syntax: adduser <user>")
@@ -207,6 +243,7 @@ var replCmds = map[string]replCmd{
 	},
 
 	"rekey": {
+		Undo: undoClear,
 		Run: func(r *repl, _ string, args []string) error {
 			var user string
 			if len(args) > 0 {
@@ -218,6 +255,7 @@ var replCmds = map[string]replCmd{
 	},
 
 	"rekeyall": {
+		Undo: undoClear,
 		Run: func(r *repl, _ string, args []string) error {
 			return r.ctx.rekeyAll()
 		},
@@ -226,10 +264,14 @@ var replCmds = map[string]replCmd{
 	"add": {
 		Run: func(r *repl, _ string, args []string) error {
 			if len(args) < 1 {
-				errColor.Println("syntax: add <name>")
+				errColor.Println("syntax: add <name> [type]")
 				return nil
 			}
-			return r.ctx.addNewInterruptible(args[0])
+			var typ string
+			if len(args) > 1 {
+				typ = args[1]
+			}
+			return r.ctx.addNewInterruptible(args[0], typ)
 		},
 	},
 
@@ -262,6 +304,23 @@ var replCmds = map[string]replCmd{
 		},
 	},
 
+	"trash": {
+		ReadOnly: true,
+		Run: func(r *repl, _ string, args []string) error {
+			return r.ctx.trash()
+		},
+	},
+
+	"undelete": {
+		Run: func(r *repl, _ string, args []string) error {
+			if len(args) < 1 {
+				errColor.Println("syntax: undelete <name|uuid>")
+				return nil
+			}
+			return r.ctx.undelete(args[0])
+		},
+	},
+
 	"rmk": {
 		Run: func(r *repl, _ string, args []string) error {
 			name := r.ctxEntry
@@ -357,16 +416,20 @@ var replCmds = map[string]replCmd{
 			// without context:
 			// set name key
 			// set name key value
+			// --secret may be given first or last, -- ends the options
+			// so a value can end in --secret
 
 			// Set's args are a special case, they are given from
 			// strings.Split not strings.Fields which means there are
 			// potentially empty string arguments lurking around.
 
 			syntaxErr := func() error {
-				errColor.Println("syntax: set <query> <key> [value]")
+				errColor.Println("syntax: set [--secret] <query> <key> [--] [value]")
 				return nil
 			}
 
+			args, secret := cutSetFlags(args)
+
 			if len(args) < 1 || (len(name) == 0 && len(args) < 2) {
 				return syntaxErr()
 			}
@@ -389,7 +452,7 @@ var replCmds = map[string]replCmd{
 				value = strings.Join(args, " ")
 			}
 
-			return r.ctx.set(name, key, value)
+			return r.ctx.set(name, key, value, secret)
 		},
 	},
 
@@ -459,6 +522,40 @@ var replCmds = map[string]replCmd{
 		},
 	},
 
+	"rmsecret": {
+		Run: func(r *repl, cmd string, args []string) error {
+			name := r.ctxEntry
+			if len(args) < 1 || (len(name) == 0 && len(args) < 2) {
+				errColor.Println("syntax: rmsecret <query> <key>")
+				return nil
+			}
+
+			if len(name) == 0 {
+				name = args[0]
+				args = args[1:]
+			}
+
+			return r.ctx.unmarkSecret(name, args[0])
+		},
+	},
+
+	"due": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			days := 14
+			if len(args) != 0 {
+				var err error
+				days, err = strconv.Atoi(args[0])
+				if err != nil || days < 0 {
+					errColor.Println("syntax: due [days]")
+					return nil
+				}
+			}
+
+			return r.ctx.due(days)
+		},
+	},
+
 	"labels": {
 		ReadOnly: true,
 		Run: func(r *repl, cmd string, args []string) error {
@@ -474,13 +571,14 @@ var replCmds = map[string]replCmd{
 	"show": {
 		ReadOnly: true,
 		Run: func(r *repl, cmd string, args []string) error {
+			args, reveal := cutFlag(args, "--reveal")
 			name := r.ctxEntry
 			snapshot := 0
 			var err error
 			if len(name) == 0 {
 				// We need to get a name
 				if len(args) == 0 {
-					errColor.Println("syntax: show <query> [snapshot]")
+					errColor.Println("syntax: show <query> [snapshot] [--reveal]")
 					return nil
 				}
 				name = args[0]
@@ -494,11 +592,70 @@ var replCmds = map[string]replCmd{
 					snapshot = 0
 				}
 			}
-			return r.ctx.show(name, snapshot)
+			return r.ctx.show(name, snapshot, reveal)
+		},
+	},
+
+	"revert": {
+		Run: func(r *repl, cmd string, args []string) error {
+			name := r.ctxEntry
+			if len(name) == 0 && len(args) != 0 {
+				name = args[0]
+				args = args[1:]
+			}
+			if len(name) == 0 || len(args) == 0 {
+				errColor.Println("syntax: revert <query> <snapshot>")
+				return nil
+			}
+
+			snapshot, err := strconv.Atoi(args[0])
+			if err != nil {
+				errColor.Println("snapshot must be a number")
+				return nil
+			}
+			return r.ctx.revert(name, snapshot)
+		},
+	},
+
+	"blame": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			name := r.ctxEntry
+			if len(name) == 0 {
+				if len(args) == 0 {
+					errColor.Println("syntax: blame <query>")
+					return nil
+				}
+				name = args[0]
+			}
+			return r.ctx.blame(name)
+		},
+	},
+
+	"history": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			args, reveal := cutFlag(args, "--reveal")
+			name := r.ctxEntry
+			if len(name) == 0 {
+				if len(args) == 0 {
+					errColor.Println("syntax: history <query> [key] [--reveal]")
+					return nil
+				}
+				name = args[0]
+				args = args[1:]
+			}
+
+			key := blobformat.KeyPass
+			if len(args) != 0 {
+				key = args[0]
+			}
+			return r.ctx.keyHistory(name, key, reveal)
 		},
 	},
 
 	"sync": {
+		Undo: undoClear,
 		Run: func(r *repl, cmd string, args []string) error {
 			var name string
 			if len(args) > 0 {
@@ -522,23 +679,126 @@ var replCmds = map[string]replCmd{
 	"dump": {
 		ReadOnly: true,
 		Run: func(r *repl, cmd string, args []string) error {
+			args, reveal := cutFlag(args, "--reveal")
 			name := r.ctxEntry
 			if len(name) == 0 {
 				if len(args) == 0 {
-					errColor.Println("syntax: dump <query>")
+					errColor.Println("syntax: dump <query> [--reveal]")
 					return nil
 				}
 				name = args[0]
 			}
 
-			return r.ctx.dump(name)
+			return r.ctx.dump(name, reveal)
 		},
 	},
 
 	"dumpall": {
 		ReadOnly: true,
 		Run: func(r *repl, cmd string, args []string) error {
-			return r.ctx.dumpall()
+			_, reveal := cutFlag(args, "--reveal")
+			return r.ctx.dumpall(reveal)
+		},
+	},
+
+	"compact": {
+		Undo: undoClear,
+		Run: func(r *repl, cmd string, args []string) error {
+			if len(args) == 0 {
+				errColor.Println("syntax: compact <date>")
+				return nil
+			}
+
+			before, err := time.ParseInLocation(dateLayout, args[0], time.Local)
+			if err != nil {
+				errColor.Println("failed to parse the date, format:", dateLayout)
+				return nil
+			}
+
+			return r.ctx.compact(before)
+		},
+	},
+
+	"purge": {
+		Undo: undoClear,
+		Run: func(r *repl, cmd string, args []string) error {
+			name := r.ctxEntry
+			if len(name) == 0 {
+				if len(args) == 0 {
+					errColor.Println("syntax: purge <query> [key]")
+					return nil
+				}
+				name = args[0]
+				args = args[1:]
+			}
+
+			key := ""
+			if len(args) != 0 {
+				key = args[0]
+			}
+
+			return r.ctx.purge(name, key)
+		},
+	},
+
+	"at": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			return r.ctx.at(args)
+		},
+	},
+
+	"diff": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			args, reveal := cutFlag(args, "--reveal")
+			name, from, to, ok := parseDiffArgs(r.ctxEntry, args)
+			if !ok {
+				errColor.Println("syntax: diff [query] <from> [to] [--reveal]")
+				return nil
+			}
+
+			return r.ctx.diff(name, from, to, reveal)
+		},
+	},
+
+	"log": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			filter, asJSON, err := parseLogArgs(args)
+			if err != nil {
+				errColor.Println(err)
+				errColor.Println("syntax: log [query] [--since t] [--until t] [--kind k,...] [--user u] [--json]")
+				return nil
+			}
+			if len(filter.search) == 0 {
+				filter.search = r.ctxEntry
+			}
+			return r.ctx.txLog(filter, asJSON)
+		},
+	},
+
+	"audit": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			days := defaultAuditAge
+			if len(args) != 0 {
+				var err error
+				days, err = strconv.Atoi(args[0])
+				if err != nil || days < 0 {
+					errColor.Println("syntax: audit [days]")
+					return nil
+				}
+			}
+
+			return r.ctx.audit(days)
+		},
+	},
+
+	"verify": {
+		ReadOnly: true,
+		Run: func(r *repl, cmd string, args []string) error {
+			return r.ctx.verify()
 		},
 	},
 
@@ -558,6 +818,28 @@ var replCmds = map[string]replCmd{
 		},
 	},
 
+	"undo": {
+		Undo: undoSelf,
+		Run: func(r *repl, cmd string, args []string) error {
+			n, ok := undoCount(cmd, args)
+			if !ok {
+				return nil
+			}
+			return r.ctx.undoN(n)
+		},
+	},
+
+	"redo": {
+		Undo: undoSelf,
+		Run: func(r *repl, cmd string, args []string) error {
+			n, ok := undoCount(cmd, args)
+			if !ok {
+				return nil
+			}
+			return r.ctx.redoN(n)
+		},
+	},
+
 	"exit": {
 		ReadOnly: true,
 		Run: func(r *repl, cmd string, args []string) error {
@@ -566,13 +848,127 @@ var replCmds = map[string]replCmd{
 	},
 }
 
+// undoCount parses the optional count of undo and redo
+func undoCount(cmd string, args []string) (n int, ok bool) {
+	if len(args) == 0 {
+		return 1, true
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		errColor.Printf("syntax: %s [n]\n", cmd)
+		return 0, false
+	}
+	return n, true
+}
+
+// cutFlag removes flag from args, found is true if it was there
+func cutFlag(args []string, flag string) (rest []string, found bool) {
+	for _, a := range args {
+		if a == flag {
+			found = true
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return rest, found
+}
+
+// cutSetFlags removes --secret from the start or end of set's args and the
+// first -- which ends the options, after it --secret is part of the value
+func cutSetFlags(args []string) (rest []string, secret bool) {
+	if len(args) != 0 && args[0] == "--secret" {
+		secret = true
+		args = args[1:]
+	}
+
+	for i, a := range args {
+		if a == "--" {
+			rest = append(rest, args[:i]...)
+			return append(rest, args[i+1:]...), secret
+		}
+	}
+
+	if !secret && len(args) != 0 && args[len(args)-1] == "--secret" {
+		secret = true
+		args = args[:len(args)-1]
+	}
+	return args, secret
+}
+
+// parseDiffArgs parses diff [query] <from> [to]. A query is only taken from
+// the arguments if there's no entry to use, when there are two arguments
+// they're the points if both look like one and a query and from otherwise.
+// --from and --to can be used to give the points instead, which makes a
+// query that looks like a point a query.
+func parseDiffArgs(entry string, args []string) (name, from, to string, ok bool) {
+	args, from, hasFrom := cutFlagValue(args, "--from")
+	args, to, hasTo := cutFlagValue(args, "--to")
+	if (hasFrom && len(from) == 0) || (hasTo && len(to) == 0) {
+		return "", "", "", false
+	}
+
+	name = entry
+	if hasFrom || hasTo {
+		switch {
+		case !hasFrom || len(args) > 1 || (len(args) == 1 && len(name) != 0):
+			return "", "", "", false
+		case len(args) == 1:
+			name = args[0]
+		}
+		return name, from, to, true
+	}
+
+	if len(name) == 0 && (len(args) == 3 || (len(args) == 2 && !(isPoint(args[0]) && isPoint(args[1])))) {
+		name = args[0]
+		args = args[1:]
+	}
+
+	switch len(args) {
+	case 1:
+		return name, args[0], "", true
+	case 2:
+		return name, args[0], args[1], true
+	default:
+		return "", "", "", false
+	}
+}
+
+// cutFlagValue removes flag and the value after it from args, found is true
+// if flag was there even if it had no value
+func cutFlagValue(args []string, flag string) (rest []string, value string, found bool) {
+	for i := 0; i < len(args); i++ {
+		if args[i] != flag {
+			rest = append(rest, args[i])
+			continue
+		}
+		found = true
+		if i+1 < len(args) {
+			value = args[i+1]
+			i++
+		}
+	}
+	return rest, value, found
+}
+
 func getCopy(r *repl, cmd string, args []string) error {
 	name := r.ctxEntry
-	if len(args) < 1 || (len(args) < 2 && len(name) == 0) {
-		errColor.Printf("syntax: %s <query> <key> [index]\n", cmd)
+	args, history, hasHistory := cutFlagValue(args, "--history")
+	if len(args) < 1 || (len(args) < 2 && len(name) == 0) || (hasHistory && len(history) == 0) {
+		errColor.Printf("syntax: %s <query> <key> [index] [--history <n>]\n", cmd)
 		return nil
 	}
 
+	past := 0
+	if hasHistory {
+		n, err := strconv.Atoi(history)
+		if err != nil || n < 1 {
+			errColor.Println("History must be the number of a value in the history command")
+			return nil
+		}
+		past = n
+	}
+
 	if len(name) == 0 {
 		name = args[0]
 		args = args[1:]
@@ -591,7 +987,7 @@ func getCopy(r *repl, cmd string, args []string) error {
 		index = i
 	}
 
-	return r.ctx.get(name, key, index, cmd == "cp")
+	return r.ctx.get(name, key, index, past, cmd == "cp")
 }
 
 func quickCopy(r *repl, cmd string, args []string) error {
@@ -606,5 +1002,5 @@ func quickCopy(r *repl, cmd string, args []string) error {
 		args = args[1:]
 	}
 
-	return r.ctx.get(name, cmd, -1, true)
+	return r.ctx.get(name, cmd, -1, 0, true)
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCutFlagValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Args  []string
+		Rest  []string
+		Value string
+		Found bool
+	}{
+		{[]string{"github", "labels", "2"}, []string{"github", "labels", "2"}, "", false},
+		{[]string{"github", "pass", "--history", "3"}, []string{"github", "pass"}, "3", true},
+		{[]string{"--history", "3", "labels", "2"}, []string{"labels", "2"}, "3", true},
+		{[]string{"github", "pass", "--history"}, []string{"github", "pass"}, "", true},
+	}
+
+	for i, test := range tests {
+		rest, value, found := cutFlagValue(test.Args, "--history")
+		if !reflect.DeepEqual(rest, test.Rest) || value != test.Value || found != test.Found {
+			t.Errorf("%d) got %q %q %t", i, rest, value, found)
+		}
+	}
+}
+
+func TestParseDiffArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Entry string
+		Args  []string
+		Name  string
+		From  string
+		To    string
+		OK    bool
+	}{
+		{Args: []string{"5"}, From: "5", OK: true},
+		{Args: []string{"5", "10"}, From: "5", To: "10", OK: true},
+		{Args: []string{"2026-10-01", "2026-10-16T12:00"}, From: "2026-10-01", To: "2026-10-16T12:00", OK: true},
+		{Args: []string{"github", "5"}, Name: "github", From: "5", OK: true},
+		{Args: []string{"github", "5", "10"}, Name: "github", From: "5", To: "10", OK: true},
+		{Entry: "github", Args: []string{"5", "10"}, Name: "github", From: "5", To: "10", OK: true},
+		// A query that looks like a point needs the flags
+		{Args: []string{"2026", "--from", "5"}, Name: "2026", From: "5", OK: true},
+		{Args: []string{"--from", "5", "--to", "10"}, From: "5", To: "10", OK: true},
+		{Entry: "github", Args: []string{"--from", "5"}, Name: "github", From: "5", OK: true},
+		{},
+		{Args: []string{"github", "5", "10", "11"}},
+		{Entry: "github", Args: []string{"gitlab", "5", "10"}},
+		{Args: []string{"--to", "10"}},
+		{Args: []string{"--from"}},
+		{Entry: "github", Args: []string{"gitlab", "--from", "5"}},
+	}
+
+	for i, test := range tests {
+		name, from, to, ok := parseDiffArgs(test.Entry, test.Args)
+		if name != test.Name || from != test.From || to != test.To || ok != test.OK {
+			t.Errorf("%d) got %q %q %q %t", i, name, from, to, ok)
+		}
+	}
+}
+
+func TestCutSetFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Args   []string
+		Rest   []string
+		Secret bool
+	}{
+		{[]string{"github", "pass", "hunter2"}, []string{"github", "pass", "hunter2"}, false},
+		{[]string{"--secret", "github", "pin", "1234"}, []string{"github", "pin", "1234"}, true},
+		{[]string{"github", "pin", "1234", "--secret"}, []string{"github", "pin", "1234"}, true},
+		{[]string{"github", "pin", "--secret"}, []string{"github", "pin"}, true},
+		// After -- it's part of the value
+		{[]string{"github", "notes", "--", "use", "--secret"}, []string{"github", "notes", "use", "--secret"}, false},
+		{[]string{"--secret", "github", "notes", "--", "--secret"}, []string{"github", "notes", "--secret"}, true},
+		{[]string{"github", "notes", "--", "--"}, []string{"github", "notes", "--"}, false},
+	}
+
+	for i, test := range tests {
+		rest, secret := cutSetFlags(test.Args)
+		if !reflect.DeepEqual(rest, test.Rest) || secret != test.Secret {
+			t.Errorf("%d) got %q %t", i, rest, secret)
+		}
+	}
+}
@@ -109,7 +109,7 @@ Syncs:
 			continue
 		}
 
-		log, err := txlogs.NewLog(pt)
+		log, err := txlogs.NewLogCodec(pt, fileCodec(params, pt))
 		if err != nil {
 			errColor.Printf("failed parsing log %q: %v\n", name, err)
 			syncs[i] = ""
@@ -117,8 +117,8 @@ Syncs:
 		}
 
 		if len(log) == len(u.store.DB.Log) &&
-			log[0].Time == u.store.DB.Log[0].Time &&
-			log[len(log)-1].Time == u.store.DB.Log[len(u.store.DB.Log)-1].Time {
+			log[0].ID == u.store.DB.Log[0].ID &&
+			log[len(log)-1].ID == u.store.DB.Log[len(u.store.DB.Log)-1].ID {
 			infoColor.Printf("skip: %s (no changes)\n", name)
 			syncs[i] = ""
 			continue
@@ -139,14 +139,15 @@ Syncs:
 	}
 
 	u.user, u.pass = out.User, out.Pass
+	u.store.User = u.user
 	u.key, u.salt = out.Key, out.Salt
 	u.master, u.ivm = out.Master, out.IVM
 
-	u.store.ResetSnapshot()
-	u.store.Log = out.Log
+	u.store.ReplaceLog(out.Log)
 	if err = u.store.UpdateSnapshot(); err != nil {
 		errColor.Println("failed to rebuild snapshot, poisoned by sync:", err)
 		errColor.Println("exiting to avoid corrupting local file")
+		errColor.Println("run \"bpass fsck\" on the file that was synced with to repair it")
 		os.Exit(1)
 	}
 
@@ -167,7 +168,7 @@ Syncs:
 	if err != nil {
 		return err
 	}
-	if ct, err = crypt.Encrypt(cryptVersion, params, pt); err != nil {
+	if ct, err = crypt.Encrypt(u.saveVersion(), params, pt); err != nil {
 		return err
 	}
 
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aarondl/bpass/blobformat"
+	"github.com/aarondl/bpass/fuzzy"
+	"github.com/aarondl/bpass/txlogs"
+)
+
+// logFilter restricts the transactions shown by the log command, zero
+// values don't restrict anything
+type logFilter struct {
+	search string
+	since  time.Time
+	until  time.Time
+	kinds  []txlogs.TxKind
+	user   string
+}
+
+// logRecord is a transaction as it's shown to humans, the value of secret
+// keys is masked
+type logRecord struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	User  string    `json:"user,omitempty"`
+	Kind  string    `json:"kind"`
+	UUID  string    `json:"uuid,omitempty"`
+	Name  string    `json:"name,omitempty"`
+	Key   string    `json:"key,omitempty"`
+	Value string    `json:"value,omitempty"`
+}
+
+// txLog prints the transactions in the log that match the filter
+func (u *uiContext) txLog(filter logFilter, asJSON bool) error {
+	records, err := logRecords(u.store.DB, filter)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(u.out)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []logRecord{}
+		}
+		return enc.Encode(records)
+	}
+
+	if len(records) == 0 {
+		infoColor.Println("no transactions")
+		return nil
+	}
+
+	for _, r := range records {
+		var b strings.Builder
+		b.WriteString(r.Time.Format(historyLayout))
+		if len(r.User) != 0 {
+			fmt.Fprintf(&b, " %s", r.User)
+		}
+		fmt.Fprintf(&b, " %-5s", r.Kind)
+		if len(r.Name) != 0 {
+			fmt.Fprintf(&b, " %s", r.Name)
+		} else if len(r.UUID) != 0 {
+			fmt.Fprintf(&b, " %s", r.UUID)
+		}
+		if len(r.Key) != 0 {
+			fmt.Fprintf(&b, " %s", keyColor.Sprint(r.Key))
+		}
+		if len(r.Value) != 0 {
+			// Keep multi-line values (like notes) on one line
+			fmt.Fprintf(&b, " %s", strings.ReplaceAll(r.Value, "\n", `\n`))
+		}
+		fmt.Fprintln(u.out, b.String())
+	}
+
+	return nil
+}
+
+// logRecords returns the transactions that match the filter with the
+// names of the entries they changed as they were at the time
+func logRecords(db *txlogs.DB, filter logFilter) ([]logRecord, error) {
+	names, err := firstNames(db)
+	if err != nil {
+		return nil, err
+	}
+	secret, _, err := secretTxs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []logRecord
+	for i, tx := range db.Log {
+		if tx.Kind == txlogs.TxSetKey && tx.Key == blobformat.KeyName && len(tx.Purged) == 0 {
+			names[tx.UUID] = tx.Value
+		}
+
+		when := time.Unix(0, tx.Time)
+		switch {
+		case !filter.since.IsZero() && when.Before(filter.since):
+			continue
+		case !filter.until.IsZero() && when.After(filter.until):
+			continue
+		case len(filter.user) != 0 && tx.User != filter.user:
+			continue
+		case len(filter.kinds) != 0 && !hasKind(filter.kinds, tx.Kind):
+			continue
+		case len(filter.search) != 0 && !fuzzy.Match(names[tx.UUID], filter.search):
+			continue
+		}
+
+		r := logRecord{
+			ID:   tx.ID,
+			Time: when,
+			User: tx.User,
+			Kind: string(tx.Kind),
+			UUID: tx.UUID,
+			Name: names[tx.UUID],
+			Key:  tx.Key,
+		}
+
+		switch tx.Kind {
+		case txlogs.TxSetKey, txlogs.TxAddItem:
+			r.Value = tx.Value
+			if len(tx.Purged) != 0 {
+				r.Value = "(purged)"
+			} else if len(r.Value) != 0 && secret[i] {
+				r.Value = "********"
+			}
+		case txlogs.TxDeleteItem:
+			r.Value = tx.Index
+		case txlogs.TxPurge:
+			r.Value = fmt.Sprintf("%d values", strings.Count(tx.Value, ",")+1)
+		case txlogs.TxRepair:
+			r.Value = fmt.Sprintf("%d transactions", strings.Count(tx.Value, ",")+1)
+		case txlogs.TxCheckpoint:
+			// The key is the checkpoint's checksum
+			r.Key = ""
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// firstNames returns the first name each entry had so that transactions
+// made before the name was set (like the add) can be named
+func firstNames(db *txlogs.DB) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(db.Log) != 0 && db.Log[0].Kind == txlogs.TxCheckpoint {
+		view, err := db.AtVersion(1)
+		if err != nil {
+			return nil, err
+		}
+		for _, uuid := range view.UUIDs() {
+			names[uuid] = blobformat.Blob(view.Entry(uuid)).Name()
+		}
+	}
+
+	for _, tx := range db.Log {
+		if tx.Kind != txlogs.TxSetKey || tx.Key != blobformat.KeyName || len(tx.Purged) != 0 {
+			continue
+		}
+		if _, ok := names[tx.UUID]; !ok {
+			names[tx.UUID] = tx.Value
+		}
+	}
+
+	return names, nil
+}
+
+func hasKind(kinds []txlogs.TxKind, kind txlogs.TxKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// logSubcommand is the log command run from the command line, it always
+// outputs json
+func logSubcommand(u *uiContext) (err error) {
+	filter := logFilter{search: flagLogQuery, user: flagLogUser}
+	if len(flagLogSince) != 0 {
+		if filter.since, err = parseLogTime(flagLogSince, false); err != nil {
+			return err
+		}
+	}
+	if len(flagLogUntil) != 0 {
+		if filter.until, err = parseLogTime(flagLogUntil, true); err != nil {
+			return err
+		}
+	}
+	if len(flagLogKind) != 0 {
+		for _, k := range strings.Split(flagLogKind, ",") {
+			filter.kinds = append(filter.kinds, txlogs.TxKind(k))
+		}
+	}
+
+	return u.txLog(filter, true)
+}
+
+// parseLogArgs parses the arguments of the log command. Times are a date
+// (YYYY-MM-DD) optionally followed by a time (HH:MM:SS).
+func parseLogArgs(args []string) (filter logFilter, asJSON bool, err error) {
+	value := func(i int) (string, int, error) {
+		if i+1 >= len(args) {
+			return "", i, fmt.Errorf("%s needs a value", args[i])
+		}
+		return args[i+1], i + 1, nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		var v string
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--since", "--until":
+			flag := args[i]
+			if v, i, err = value(i); err != nil {
+				return filter, false, err
+			}
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				if _, err := time.Parse("15:04:05", args[i+1]); err == nil {
+					i++
+					v += " " + args[i]
+				}
+			}
+
+			t, err := parseLogTime(v, flag == "--until")
+			if err != nil {
+				return filter, false, err
+			}
+			if flag == "--since" {
+				filter.since = t
+			} else {
+				filter.until = t
+			}
+		case "--kind":
+			if v, i, err = value(i); err != nil {
+				return filter, false, err
+			}
+			for _, k := range strings.Split(v, ",") {
+				filter.kinds = append(filter.kinds, txlogs.TxKind(k))
+			}
+		case "--user":
+			if filter.user, i, err = value(i); err != nil {
+				return filter, false, err
+			}
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return filter, false, fmt.Errorf("unknown option %s", args[i])
+			}
+			if len(filter.search) != 0 {
+				return filter, false, errors.New("only one query may be given")
+			}
+			filter.search = args[i]
+		}
+	}
+
+	return filter, asJSON, nil
+}
+
+// parseLogTime parses a date or a date and time in local time, a date on its
+// own is the end of the day if it's the end of a range so the whole day is
+// included
+func parseLogTime(when string, end bool) (time.Time, error) {
+	t, err := time.ParseInLocation(historyLayout, when, time.Local)
+	if err != nil {
+		t, err = time.ParseInLocation(dateLayout, when, time.Local)
+		if err == nil && end {
+			t = t.AddDate(0, 0, 1).Add(-1)
+		}
+	}
+	if err != nil {
+		return t, fmt.Errorf("failed to parse %q, use the format: %s or %s",
+			when, dateLayout, historyLayout)
+	}
+	return t, nil
+}
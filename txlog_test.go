@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		When string
+		End  bool
+		Want time.Time
+	}{
+		{"2026-10-16 13:14:15", false, time.Date(2026, 10, 16, 13, 14, 15, 0, time.Local)},
+		{"2026-10-16 13:14:15", true, time.Date(2026, 10, 16, 13, 14, 15, 0, time.Local)},
+		{"2026-10-16", false, time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)},
+		{"2026-10-16", true, time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local).Add(-1)},
+	}
+
+	for _, test := range tests {
+		got, err := parseLogTime(test.When, test.End)
+		if err != nil {
+			t.Error(err)
+		} else if !got.Equal(test.Want) || got.Location() != time.Local {
+			t.Errorf("%s (end %t): want %v, got %v", test.When, test.End, test.Want, got)
+		}
+	}
+
+	if _, err := parseLogTime("16/10/2026", false); err == nil {
+		t.Error("expected an error")
+	}
+}
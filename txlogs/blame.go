@@ -0,0 +1,61 @@
+package txlogs
+
+import (
+	"sort"
+	"time"
+)
+
+// Blame is who last changed a key and when. User is empty if the change was
+// not made in a multi-user file or the key came from a checkpoint.
+type Blame struct {
+	Key  string
+	User string
+	Time time.Time
+}
+
+// Blame returns who last changed each of the keys an entry has, in
+// alphabetical order.
+func (s *DB) Blame(uuid string) ([]Blame, error) {
+	if err := s.UpdateSnapshot(); err != nil {
+		return nil, err
+	}
+
+	entry, ok := s.Snapshot[uuid]
+	if !ok {
+		return nil, UUIDNotFound(uuid)
+	}
+
+	last := make(map[string]Tx)
+	for _, i := range s.entryTxs(uuid) {
+		tx := s.Log[i]
+		switch tx.Kind {
+		case TxSetKey, TxAddItem, TxDeleteItem:
+			last[tx.Key] = tx
+		case TxCheckpoint:
+			// It's always first and stands in for whatever was changed
+			// before it, keys changed after it replace it
+			for k := range entry {
+				last[k] = tx
+			}
+		}
+	}
+
+	blames := make([]Blame, 0, len(entry))
+	for k := range entry {
+		if IsItemKey(k) {
+			continue
+		}
+
+		tx := last[k]
+		blames = append(blames, Blame{
+			Key:  k,
+			User: tx.User,
+			Time: time.Unix(0, tx.Time),
+		})
+	}
+	sort.Slice(blames, func(i, j int) bool {
+		return blames[i].Key < blames[j].Key
+	})
+
+	return blames, nil
+}
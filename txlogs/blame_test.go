@@ -0,0 +1,50 @@
+package txlogs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlame(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{User: "alice"}
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "1")
+	store.Set(uuid, "b", "1")
+	store.AddItem(uuid, "labels", "x")
+
+	store.User = "bob"
+	store.Set(uuid, "b", "2")
+	store.DeleteKey(uuid, "a")
+	store.AddItem(uuid, "labels", "y")
+
+	if store.Log[0].User != "alice" || store.Log[len(store.Log)-1].User != "bob" {
+		t.Error("users were not recorded")
+	}
+	must(t, store.Verify())
+
+	blames, err := store.Blame(uuid)
+	must(t, err)
+
+	if len(blames) != 2 {
+		t.Fatalf("wrong number of keys: %#v", blames)
+	}
+	if b := blames[0]; b.Key != "b" || b.User != "bob" || !b.Time.Equal(time.Unix(0, store.Log[4].Time)) {
+		t.Errorf("b was wrong: %#v", b)
+	}
+	if b := blames[1]; b.Key != "labels" || b.User != "bob" {
+		t.Errorf("labels was wrong: %#v", b)
+	}
+
+	// Changing the author breaks the chain
+	store.Log[1].User = "mallory"
+	if err := store.Verify(); !IsBrokenChain(err) {
+		t.Error("expected a broken chain:", err)
+	}
+
+	if _, err = store.Blame("nope"); !IsUUIDNotFound(err) {
+		t.Error("expected uuid not found:", err)
+	}
+}
@@ -0,0 +1,208 @@
+package txlogs
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// Each transaction holds the hash of the one before it in Prev, making the
+// log a hash chain. Changing any transaction changes its hash which breaks
+// the link from the transaction after it, so rewriting history can be
+// detected with Verify.
+//
+// Because the hash of a transaction covers the whole log before it, two
+// logs that have a transaction with the same hash at the same index are
+// identical up to that point. Merge uses this to find where two logs forked
+// without comparing every transaction.
+//
+// A checkpoint stands in for all the transactions it folded so its hash is
+// the hash of the last one (which it keeps in Prev) rather than its own.
+// This keeps the links of the transactions after it intact.
+
+// hashTx returns the hash that the next transaction links to
+func hashTx(tx Tx) string {
+	if tx.Kind == TxCheckpoint {
+		return tx.Prev
+	}
+
+	fields := []string{
+		tx.Prev,
+		tx.ID,
+		strconv.FormatInt(tx.Time, 10),
+		string(tx.Kind),
+		tx.UUID,
+		tx.Key,
+		valueDigest(tx),
+	}
+	if len(tx.Index) != 0 {
+		// Only list transactions have this, leaving it out otherwise keeps
+		// the hashes of everything else the same as they've always been
+		fields = append(fields, tx.Index)
+	}
+	if len(tx.User) != 0 {
+		// Same for transactions from multi-user files, the name is
+		// included so authorship can't be changed without breaking the
+		// chain
+		fields = append(fields, "user", tx.User)
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		// Length prefix each field so they can't be shifted into each other
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// valueDigest is the part of the hash that covers the value, redacted
+// transactions keep the digest of the value they had.
+//
+// Sets are salted so that once they're redacted (and the salt with them) the
+// digest can't be used to guess what the value was. Sets made before salts
+// existed aren't, changing their digest would break the chain.
+func valueDigest(tx Tx) string {
+	if len(tx.Purged) != 0 {
+		return tx.Purged
+	}
+
+	if len(tx.Salt) == 0 {
+		sum := sha256.Sum256([]byte(tx.Value))
+		return hex.EncodeToString(sum[:])
+	}
+
+	h := sha256.New()
+	h.Write([]byte(tx.Salt))
+	h.Write([]byte{':'})
+	h.Write([]byte(tx.Value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newSalt returns a random salt for a set, see valueDigest
+func newSalt() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// chained checks if a log has been hash chained, logs saved before chains
+// existed have no links at all.
+func chained(log []Tx) bool {
+	for i := 1; i < len(log); i++ {
+		if len(log[i].Prev) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// migrateChain links logs that were saved before chains existed. Logs that
+// have any links are left alone, otherwise removing the links would be a
+// way to hide tampering.
+func migrateChain(log []Tx) {
+	if chained(log) {
+		return
+	}
+
+	chain(log, 1)
+}
+
+// chain links every transaction from start to the one before it
+func chain(log []Tx, start int) {
+	if start < 1 {
+		start = 1
+	}
+	for i := start; i < len(log); i++ {
+		log[i].Prev = hashTx(log[i-1])
+	}
+}
+
+// brokenChain verifies a log and returns the transaction where it's broken,
+// logs saved before chains existed can't be verified
+func brokenChain(log []Tx) (Tx, bool) {
+	if !chained(log) {
+		return Tx{}, false
+	}
+
+	var broken BrokenChain
+	if err := (&DB{Log: log}).Verify(); errors.As(err, &broken) {
+		return broken.Tx, true
+	}
+	return Tx{}, false
+}
+
+// head returns the hash of the last transaction in the log
+func head(log []Tx) string {
+	if len(log) == 0 {
+		return ""
+	}
+	return hashTx(log[len(log)-1])
+}
+
+// forkPoint returns the length of the history that a and b share. Both
+// logs must be chained.
+func forkPoint(a, b []Tx) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	// Sharing a prefix of length i means sharing every shorter one so the
+	// first length that isn't shared can be found with a binary search
+	return sort.Search(n, func(i int) bool {
+		return hashTx(a[i]) != hashTx(b[i])
+	})
+}
+
+// Verify checks every link in the hash chain, as well as the checksum of a
+// checkpoint at the start of the log. It returns a BrokenChain error for
+// the first transaction that does not link to the one before it.
+//
+// The hash of a redacted transaction covers the digest it keeps instead of
+// its value so redacted transactions must have no value (or salt) and be
+// listed by a purge, otherwise their values could be changed freely.
+func (s *DB) Verify() error {
+	if len(s.Log) == 0 {
+		return nil
+	}
+
+	purged := purgeTargets(s.Log)
+
+	if s.Log[0].Kind == TxCheckpoint {
+		if _, err := checkpointSnapshot(s.Log[0]); err != nil {
+			return BrokenChain{Index: 0, Tx: s.Log[0], Reason: err.Error()}
+		}
+	}
+
+	for i := 1; i < len(s.Log); i++ {
+		if s.Log[i].Kind == TxCheckpoint {
+			return BrokenChain{Index: i, Tx: s.Log[i], Reason: "checkpoint is not at the start of the log"}
+		}
+		if s.Log[i].Prev != hashTx(s.Log[i-1]) {
+			return BrokenChain{Index: i, Tx: s.Log[i], Reason: "link to previous transaction does not match"}
+		}
+	}
+
+	for i, tx := range s.Log {
+		if len(tx.Purged) == 0 {
+			continue
+		}
+		if len(tx.Value) != 0 || len(tx.Salt) != 0 {
+			return BrokenChain{Index: i, Tx: tx, Reason: "redacted transaction has a value"}
+		}
+		if _, ok := purged[txID(tx)+"\x00"+tx.Key]; !ok || tx.Kind != TxSetKey {
+			return BrokenChain{Index: i, Tx: tx, Reason: "redacted transaction was not purged"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package txlogs
+
+import (
+	"testing"
+	"time"
+)
+
+func chainedStore(t *testing.T) (*DB, string) {
+	t.Helper()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "b")
+	store.Set(uuid, "c", "d")
+	store.DeleteKey(uuid, "a")
+
+	return store, uuid
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	store, _ := chainedStore(t)
+	must(t, store.Verify())
+
+	store.Log[1].Value = "tampered"
+	err := store.Verify()
+	if !IsBrokenChain(err) {
+		t.Fatal("expected a broken chain error:", err)
+	}
+	if broken := err.(BrokenChain); broken.Index != 2 {
+		t.Error("wrong index for the broken link:", broken.Index)
+	}
+}
+
+func TestVerifyPurged(t *testing.T) {
+	t.Parallel()
+
+	store, uuid := chainedStore(t)
+	store.Set(uuid, "c", "e")
+	must(t, store.UpdateSnapshot())
+	_, err := store.Purge(uuid, "c")
+	must(t, err)
+	must(t, store.Verify())
+
+	// The value of a purged transaction isn't covered by its hash
+	tampered := &DB{Log: make([]Tx, len(store.Log))}
+	copy(tampered.Log, store.Log)
+	tampered.Log[2].Value = "tampered"
+	if err = tampered.Verify(); !IsBrokenChain(err) {
+		t.Error("expected a broken chain error for a value:", err)
+	}
+
+	// Marking a transaction purged without a purge listing it keeps its
+	// hash the same, so its value could be hidden or changed
+	copy(tampered.Log, store.Log)
+	tampered.Log[1].Purged = valueDigest(tampered.Log[1])
+	tampered.Log[1].Value = ""
+	if err = tampered.Verify(); !IsBrokenChain(err) {
+		t.Error("expected a broken chain error for a fake purge:", err)
+	}
+}
+
+func TestVerifyCompacted(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	migrateIDs(store.Log)
+	migrateChain(store.Log)
+
+	_, err := store.Compact(time.Unix(0, 5))
+	must(t, err)
+	must(t, store.Verify())
+
+	store.Log[0].Value = `{"1":{"a":"hacked"}}`
+	if err = store.Verify(); !IsBrokenChain(err) {
+		t.Error("expected a broken chain error:", err)
+	}
+}
+
+func TestMigrateChain(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{"log":[
+		{"time":1,"kind":"add","uuid":"1"},
+		{"time":2,"kind":"setk","uuid":"1","key":"a","value":"b"},
+		{"time":3,"kind":"setk","uuid":"1","key":"a","value":"c"}
+	]}`)
+
+	store, err := New(data)
+	must(t, err)
+	must(t, store.Verify())
+
+	// Two copies of the same old file chain the same way
+	log, err := NewLog(data)
+	must(t, err)
+	if head(log) != head(store.Log) {
+		t.Error("chains should be identical")
+	}
+
+	// A chained log with a link removed is not re-chained
+	store.Log[2].Prev = ""
+	b, err := store.Save()
+	must(t, err)
+	store, err = New(b)
+	must(t, err)
+	if err = store.Verify(); !IsBrokenChain(err) {
+		t.Error("expected a broken chain error:", err)
+	}
+}
+
+func TestMergeChained(t *testing.T) {
+	t.Parallel()
+
+	a, uuid := chainedStore(t)
+	b := &DB{Log: make([]Tx, len(a.Log))}
+	copy(b.Log, a.Log)
+
+	a.Set(uuid, "x", "1")
+	b.Set(uuid, "y", "2")
+	a.Set(uuid, "z", "3")
+
+	if n := forkPoint(a.Log, b.Log); n != 4 {
+		t.Error("fork point was wrong:", n)
+	}
+
+	merged, conflicts := Merge(a.Log, b.Log, nil)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts should be empty: %#v", conflicts)
+	}
+	if len(merged) != 7 {
+		t.Fatal("merged length wrong:", len(merged))
+	}
+
+	store := &DB{Log: merged}
+	must(t, store.Verify())
+
+	// The other side must end up with the exact same chain
+	other, conflicts := Merge(b.Log, a.Log, nil)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts should be empty: %#v", conflicts)
+	}
+	if head(other) != head(merged) {
+		t.Error("both sides should have the same head")
+	}
+
+	// And merging the result back in is a no-op
+	again, _ := Merge(merged, other, nil)
+	if len(again) != len(merged) {
+		t.Error("merging identical chains should be a no-op")
+	}
+}
+
+func TestMergeTampered(t *testing.T) {
+	t.Parallel()
+
+	a, uuid := chainedStore(t)
+	b := &DB{Log: make([]Tx, len(a.Log))}
+	copy(b.Log, a.Log)
+	b.Set(uuid, "x", "1")
+
+	// Rewriting a value in the shared history of the remote
+	b.Log[1].Value = "tampered"
+	merged, conflicts := Merge(a.Log, b.Log, nil)
+	if len(conflicts) != 1 || conflicts[0].Kind != ConflictKindBrokenChain ||
+		txID(conflicts[0].Conflict) != txID(b.Log[2]) {
+		t.Fatalf("expected a broken chain conflict for the remote: %#v", conflicts)
+	}
+	if merged != nil {
+		t.Error("nothing should have been merged")
+	}
+
+	// Resolving the other conflicts doesn't get past it
+	if _, conflicts = Merge(b.Log, a.Log, conflicts); len(conflicts) != 1 ||
+		txID(conflicts[0].Initial) != txID(b.Log[2]) {
+		t.Errorf("expected a broken chain conflict for the local side: %#v", conflicts)
+	}
+}
@@ -0,0 +1,84 @@
+package txlogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec is how a DB is encoded by Save
+type Codec int
+
+// Codecs, the numbers are stored in files so they must not change
+const (
+	// CodecJSON is the original encoding, it's readable by humans
+	CodecJSON Codec = 0
+	// CodecMsgpack is about half the size of json and faster to parse
+	CodecMsgpack Codec = 1
+)
+
+// ParseCodec returns the codec with the name (json, msgpack)
+func ParseCodec(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return CodecJSON, nil
+	case "msgpack":
+		return CodecMsgpack, nil
+	default:
+		return 0, fmt.Errorf("unknown codec %q, use json or msgpack", name)
+	}
+}
+
+func (c Codec) String() string {
+	switch c {
+	case CodecJSON:
+		return "json"
+	case CodecMsgpack:
+		return "msgpack"
+	default:
+		return fmt.Sprintf("codec(%d)", int(c))
+	}
+}
+
+// DetectCodec returns the codec that data was encoded with. A DB is an
+// object in json so it always starts with a brace (after whitespace), in
+// msgpack it's a map which never does.
+func DetectCodec(data []byte) Codec {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', 'n':
+			// n is for null, which is what an empty DB once saved as
+			return CodecJSON
+		default:
+			return CodecMsgpack
+		}
+	}
+
+	return CodecJSON
+}
+
+func (c Codec) marshal(v interface{}) ([]byte, error) {
+	switch c {
+	case CodecJSON:
+		return json.Marshal(v)
+	case CodecMsgpack:
+		return msgpack.Marshal(v)
+	default:
+		return nil, fmt.Errorf("unknown codec %d", int(c))
+	}
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	switch c {
+	case CodecJSON:
+		return json.Unmarshal(data, v)
+	case CodecMsgpack:
+		return msgpack.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("unknown codec %d", int(c))
+	}
+}
@@ -0,0 +1,165 @@
+package txlogs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Compact folds all transactions that happened before the cutoff into a
+// single checkpoint transaction holding the snapshot of the data at that
+// point. All history before the checkpoint is lost. It returns the number
+// of transactions that were folded, which is 0 if there was nothing to do.
+//
+// The cut is made by transaction id rather than time since the log is in id
+// order, a transaction whose wall clock was wrong may have a time on the
+// other side of the cutoff from the ones around it.
+//
+// Compaction is deterministic, two copies of the same log compacted at the
+// same cutoff have identical checkpoints. Merge also knows how to fold an
+// uncompacted copy to match a checkpoint so copies that have not been
+// compacted can still be merged with ones that have.
+func (s *DB) Compact(before time.Time) (folded int, err error) {
+	if s.InTransaction() {
+		return 0, errors.New("refusing to compact while transaction active")
+	}
+
+	// An empty node sorts before every node with the same wall time
+	cutoff := formatID(before.UnixNano(), 0, "")
+	n := 0
+	for n < len(s.Log) && txID(s.Log[n]) < cutoff {
+		n++
+	}
+
+	if n == 0 || (n == 1 && s.Log[0].Kind == TxCheckpoint) {
+		return 0, nil
+	}
+
+	ckpt, err := checkpoint(s.Log[:n])
+	if err != nil {
+		return 0, err
+	}
+
+	log := make([]Tx, 0, len(s.Log)-n+1)
+	log = append(log, ckpt)
+	s.ReplaceLog(append(log, s.Log[n:]...))
+
+	return n, nil
+}
+
+// checkpoint creates a checkpoint transaction from the given log
+func checkpoint(log []Tx) (Tx, error) {
+	snap := make(map[string]Entry)
+	for _, tx := range log {
+		if err := applyTx(snap, tx); err != nil {
+			return Tx{}, err
+		}
+	}
+
+	// json sorts map keys so this encoding is deterministic
+	value, err := json.Marshal(snap)
+	if err != nil {
+		return Tx{}, err
+	}
+
+	last := log[len(log)-1]
+	return Tx{
+		ID:    txID(last),
+		Time:  last.Time,
+		Kind:  TxCheckpoint,
+		Prev:  hashTx(last),
+		Key:   checksumCheckpoint(value),
+		Value: string(value),
+	}, nil
+}
+
+// checksumCheckpoint is the sha256 of a checkpoint's value. It's not keyed
+// so it only catches corruption and edits that didn't bother to update it,
+// it doesn't prove who made the checkpoint.
+func checksumCheckpoint(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpointSnapshot verifies the checksum and decodes the snapshot
+// contained in a checkpoint transaction
+func checkpointSnapshot(tx Tx) (map[string]Entry, error) {
+	if checksumCheckpoint([]byte(tx.Value)) != tx.Key {
+		return nil, fmt.Errorf("checkpoint at %d has a bad checksum", tx.Time)
+	}
+
+	var snap map[string]Entry
+	if err := json.Unmarshal([]byte(tx.Value), &snap); err != nil {
+		return nil, fmt.Errorf("checkpoint at %d is corrupt: %w", tx.Time, err)
+	}
+
+	return snap, nil
+}
+
+// sameCheckpoint checks that a and b are the same checkpoint, Prev is the
+// hash of the history that was folded and Key is the checksum of the
+// snapshot.
+func sameCheckpoint(a, b Tx) bool {
+	return a.Kind == TxCheckpoint && b.Kind == TxCheckpoint &&
+		txID(a) == txID(b) && a.Prev == b.Prev && a.Key == b.Key
+}
+
+// alignCheckpoints tries to fold the start of one log to match a checkpoint
+// at the start of the other. If both start with a checkpoint the later one
+// is used. Neither log is modified.
+func alignCheckpoints(a, b []Tx) ([]Tx, []Tx) {
+	if len(a) == 0 || len(b) == 0 || sameCheckpoint(a[0], b[0]) {
+		return a, b
+	}
+
+	// The checkpoint may have been purged since it was made, the folded
+	// log must be purged the same way to match it
+	var purges []Tx
+	for _, log := range [][]Tx{a, b} {
+		for _, tx := range log {
+			if tx.Kind == TxPurge {
+				purges = append(purges, tx)
+			}
+		}
+	}
+
+	switch {
+	case a[0].Kind == TxCheckpoint &&
+		(b[0].Kind != TxCheckpoint || txID(b[0]) < txID(a[0])):
+		b = foldTo(b, a[0], purges)
+	case b[0].Kind == TxCheckpoint:
+		a = foldTo(a, b[0], purges)
+	}
+
+	return a, b
+}
+
+// foldTo compacts the log at the same point as the checkpoint given and
+// applies the purges to it, if the result is not identical to the
+// checkpoint the log is returned untouched.
+func foldTo(log []Tx, ckpt Tx, purges []Tx) []Tx {
+	n := 0
+	for n < len(log) && txID(log[n]) <= txID(ckpt) {
+		n++
+	}
+	if n == 0 {
+		return log
+	}
+
+	folded, err := checkpoint(log[:n])
+	if err != nil {
+		return log
+	}
+	redacted := append([]Tx{folded}, purges...)
+	redact(redacted)
+	if !sameCheckpoint(redacted[0], ckpt) {
+		return log
+	}
+
+	newLog := make([]Tx, 0, len(log)-n+1)
+	newLog = append(newLog, ckpt)
+	return append(newLog, log[n:]...)
+}
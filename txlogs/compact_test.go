@@ -0,0 +1,200 @@
+package txlogs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func compactLog() []Tx {
+	return []Tx{
+		{Time: 1, Kind: TxAdd, UUID: "1"},
+		{Time: 2, Kind: TxSetKey, UUID: "1", Key: "a", Value: "b"},
+		{Time: 3, Kind: TxAdd, UUID: "2"},
+		{Time: 4, Kind: TxSetKey, UUID: "1", Key: "a", Value: "c"},
+		{Time: 5, Kind: TxDelete, UUID: "2"},
+		{Time: 6, Kind: TxSetKey, UUID: "1", Key: "d", Value: "e"},
+	}
+}
+
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	must(t, store.UpdateSnapshot())
+	want := store.Snapshot
+
+	folded, err := store.Compact(time.Unix(0, 6))
+	must(t, err)
+
+	if folded != 5 {
+		t.Error("it should have folded 5 txs, got:", folded)
+	}
+	if len(store.Log) != 2 {
+		t.Fatal("log should have a checkpoint and 1 tx, got:", len(store.Log))
+	}
+	if store.Log[0].Kind != TxCheckpoint || store.Log[0].Time != 5 {
+		t.Errorf("checkpoint was wrong: %#v", store.Log[0])
+	}
+	if store.Snapshot != nil {
+		t.Error("snapshot should have been reset")
+	}
+
+	must(t, store.UpdateSnapshot())
+	if !reflect.DeepEqual(want, store.Snapshot) {
+		t.Errorf("snapshots differ:\nwant:\n%#v\ngot:\n%#v", want, store.Snapshot)
+	}
+
+	if n := store.NVersions("1"); n != 2 {
+		t.Error("entry should have 2 versions, got:", n)
+	}
+	if last := store.LastUpdated("1"); last != 6 {
+		t.Error("last updated wrong:", last)
+	}
+	entry, err := store.EntrySnapshotAt("1", 1)
+	must(t, err)
+	if entry["a"] != "c" {
+		t.Error("value wrong:", entry["a"])
+	}
+
+	// Nothing more to do
+	folded, err = store.Compact(time.Unix(0, 6))
+	must(t, err)
+	if folded != 0 {
+		t.Error("should not have folded anything")
+	}
+}
+
+func TestCompactSkewed(t *testing.T) {
+	t.Parallel()
+
+	// The add's wall clock was behind, its id still puts it at the cutoff
+	log := compactLog()
+	migrateIDs(log)
+	log[2].Time = 0
+
+	store := &DB{Log: log}
+	folded, err := store.Compact(time.Unix(0, 3))
+	must(t, err)
+	if folded != 2 {
+		t.Error("it should have folded the 2 txs before the add, got:", folded)
+	}
+	if store.Log[1].Kind != TxAdd || store.Log[2].Key != "a" {
+		t.Errorf("the txs after the cut were wrong: %#v", store.Log[1:3])
+	}
+	must(t, store.UpdateSnapshot())
+}
+
+func TestCompactBadChecksum(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	_, err := store.Compact(time.Unix(0, 100))
+	must(t, err)
+
+	hacked := store.Log[0]
+	store.Log[0].Value = `{"1":{"a":"hacked"}}`
+	if err := store.UpdateSnapshot(); err == nil {
+		t.Error("expected a checksum error")
+	}
+
+	// Updating the checksum makes it a different checkpoint
+	hacked.Value = `{"1":{"a":"hacked"}}`
+	hacked.Key = checksumCheckpoint([]byte(hacked.Value))
+	if sameCheckpoint(store.Log[0], hacked) {
+		t.Error("checkpoints with different snapshots should not be the same")
+	}
+}
+
+func TestCompactMerge(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SameCheckpoint", func(t *testing.T) {
+		t.Parallel()
+
+		a := &DB{Log: compactLog()}
+		b := &DB{Log: compactLog()}
+		a.Log = append(a.Log, Tx{Time: 7, Kind: TxAdd, UUID: "3"})
+		b.Log = append(b.Log, Tx{Time: 8, Kind: TxAdd, UUID: "4"})
+
+		_, err := a.Compact(time.Unix(0, 5))
+		must(t, err)
+		_, err = b.Compact(time.Unix(0, 5))
+		must(t, err)
+
+		merged, conflicts := Merge(a.Log, b.Log, nil)
+		if len(conflicts) != 0 {
+			t.Fatalf("conflicts should be empty: %#v", conflicts)
+		}
+		if len(merged) != 5 || merged[0].Kind != TxCheckpoint {
+			t.Errorf("merged was wrong: %#v", merged)
+		}
+	})
+
+	t.Run("Uncompacted", func(t *testing.T) {
+		t.Parallel()
+
+		a := &DB{Log: compactLog()}
+		b := compactLog()
+		b = append(b, Tx{Time: 8, Kind: TxAdd, UUID: "4"})
+		a.Log = append(a.Log, Tx{Time: 7, Kind: TxAdd, UUID: "3"})
+
+		_, err := a.Compact(time.Unix(0, 5))
+		must(t, err)
+
+		merged, conflicts := Merge(a.Log, b, nil)
+		if len(conflicts) != 0 {
+			t.Fatalf("conflicts should be empty: %#v", conflicts)
+		}
+
+		want := []Tx{a.Log[0], a.Log[1], a.Log[2], a.Log[3], b[6]}
+		if !reflect.DeepEqual(want, merged) {
+			t.Errorf("merged differs: %#v", merged)
+		}
+
+		// And the other way around
+		merged, conflicts = Merge(b, a.Log, nil)
+		if len(conflicts) != 0 {
+			t.Fatalf("conflicts should be empty: %#v", conflicts)
+		}
+		if !reflect.DeepEqual(want, merged) {
+			t.Errorf("merged differs: %#v", merged)
+		}
+	})
+
+	t.Run("DifferentCheckpoints", func(t *testing.T) {
+		t.Parallel()
+
+		a := &DB{Log: compactLog()}
+		b := &DB{Log: compactLog()}
+
+		_, err := a.Compact(time.Unix(0, 3))
+		must(t, err)
+		_, err = b.Compact(time.Unix(0, 5))
+		must(t, err)
+
+		merged, conflicts := Merge(a.Log, b.Log, nil)
+		if len(conflicts) != 0 {
+			t.Fatalf("conflicts should be empty: %#v", conflicts)
+		}
+		if !reflect.DeepEqual(b.Log, merged) {
+			t.Errorf("merged differs: %#v", merged)
+		}
+	})
+
+	t.Run("DivergedHistory", func(t *testing.T) {
+		t.Parallel()
+
+		a := &DB{Log: compactLog()}
+		b := compactLog()
+		b[3].Value = "z"
+
+		_, err := a.Compact(time.Unix(0, 5))
+		must(t, err)
+
+		_, conflicts := Merge(a.Log, b, nil)
+		if len(conflicts) != 1 || conflicts[0].Kind != ConflictKindRoot {
+			t.Errorf("expected a root conflict: %#v", conflicts)
+		}
+	})
+}
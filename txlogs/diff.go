@@ -0,0 +1,138 @@
+package txlogs
+
+import (
+	"sort"
+	"time"
+)
+
+// DiffKind is how something changed between two points in history
+type DiffKind int
+
+// Kinds of changes
+const (
+	DiffAdded DiffKind = iota + 1
+	DiffRemoved
+	DiffModified
+)
+
+// EntryDiff is an entry that was added, removed or modified. An added or
+// removed entry has every key it had in Keys.
+type EntryDiff struct {
+	UUID string
+	Kind DiffKind
+	Keys []KeyDiff
+}
+
+// KeyDiff is a key that was added, removed or modified. Old is empty for
+// added keys and New is empty for removed ones.
+type KeyDiff struct {
+	Key  string
+	Kind DiffKind
+	Old  string
+	New  string
+}
+
+// DiffVersions returns what changed between two versions (see AtVersion)
+func (s *DB) DiffVersions(from, to int) ([]EntryDiff, error) {
+	a, err := s.AtVersion(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.AtVersion(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Diff(b), nil
+}
+
+// DiffTimes returns what changed between two times (see At)
+func (s *DB) DiffTimes(from, to time.Time) ([]EntryDiff, error) {
+	a, err := s.At(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.At(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Diff(b), nil
+}
+
+// Diff returns what changed going from this view to the other
+func (v *View) Diff(other *View) []EntryDiff {
+	return Diff(v.snapshot, other.snapshot)
+}
+
+// Diff returns what changed going from snapshot a to b in uuid order, keys
+// are in alphabetical order. The hidden keys of lists are not compared,
+// the list key holds the same information.
+func Diff(a, b map[string]Entry) []EntryDiff {
+	uuids := make([]string, 0, len(b))
+	for uuid := range a {
+		uuids = append(uuids, uuid)
+	}
+	for uuid := range b {
+		if _, ok := a[uuid]; !ok {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+
+	var diffs []EntryDiff
+	for _, uuid := range uuids {
+		before, inA := a[uuid]
+		after, inB := b[uuid]
+
+		diff := EntryDiff{UUID: uuid, Kind: DiffModified}
+		switch {
+		case !inA:
+			diff.Kind = DiffAdded
+		case !inB:
+			diff.Kind = DiffRemoved
+		}
+
+		diff.Keys = diffEntry(before, after)
+		if len(diff.Keys) == 0 && diff.Kind == DiffModified {
+			continue
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs
+}
+
+func diffEntry(a, b Entry) []KeyDiff {
+	keys := make([]string, 0, len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var diffs []KeyDiff
+	for _, k := range keys {
+		if IsItemKey(k) {
+			continue
+		}
+
+		old, inA := a[k]
+		now, inB := b[k]
+		switch {
+		case !inA:
+			diffs = append(diffs, KeyDiff{Key: k, Kind: DiffAdded, New: now})
+		case !inB:
+			diffs = append(diffs, KeyDiff{Key: k, Kind: DiffRemoved, Old: old})
+		case old != now:
+			diffs = append(diffs, KeyDiff{Key: k, Kind: DiffModified, Old: old, New: now})
+		}
+	}
+
+	return diffs
+}
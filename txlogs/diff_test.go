@@ -0,0 +1,60 @@
+package txlogs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	migrateIDs(store.Log)
+
+	diffs, err := store.DiffVersions(2, 6)
+	must(t, err)
+
+	want := []EntryDiff{
+		{UUID: "1", Kind: DiffModified, Keys: []KeyDiff{
+			{Key: "a", Kind: DiffModified, Old: "b", New: "c"},
+			{Key: "d", Kind: DiffAdded, New: "e"},
+		}},
+	}
+	if !reflect.DeepEqual(want, diffs) {
+		t.Errorf("diff was wrong: %#v", diffs)
+	}
+
+	// Entry 2 was added and removed in between so it doesn't show up
+	diffs, err = store.DiffTimes(time.Unix(0, 3), time.Unix(0, 4))
+	must(t, err)
+
+	want = []EntryDiff{
+		{UUID: "1", Kind: DiffModified, Keys: []KeyDiff{
+			{Key: "a", Kind: DiffModified, Old: "b", New: "c"},
+		}},
+	}
+	if !reflect.DeepEqual(want, diffs) {
+		t.Errorf("diff was wrong: %#v", diffs)
+	}
+
+	diffs, err = store.DiffVersions(0, 3)
+	must(t, err)
+
+	want = []EntryDiff{
+		{UUID: "1", Kind: DiffAdded, Keys: []KeyDiff{
+			{Key: "a", Kind: DiffAdded, New: "b"},
+		}},
+		{UUID: "2", Kind: DiffAdded},
+	}
+	if !reflect.DeepEqual(want, diffs) {
+		t.Errorf("diff was wrong: %#v", diffs)
+	}
+
+	// And backwards
+	diffs, err = store.DiffVersions(3, 0)
+	must(t, err)
+	if len(diffs) != 2 || diffs[0].Kind != DiffRemoved || diffs[0].Keys[0].Old != "b" {
+		t.Errorf("diff was wrong: %#v", diffs)
+	}
+}
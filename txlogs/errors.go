@@ -56,3 +56,21 @@ func IsUUIDNotFound(err error) bool {
 	_, ok := err.(UUIDNotFound)
 	return ok
 }
+
+// BrokenChain occurs when the hash chain of the log is broken, Index is the
+// position in the log of the first transaction that doesn't fit.
+type BrokenChain struct {
+	Index  int
+	Tx     Tx
+	Reason string
+}
+
+func (b BrokenChain) Error() string {
+	return fmt.Sprintf("chain broken at transaction %d (%s): %s", b.Index, txID(b.Tx), b.Reason)
+}
+
+// IsBrokenChain checks if the error is a broken chain error
+func IsBrokenChain(err error) bool {
+	_, ok := err.(BrokenChain)
+	return ok
+}
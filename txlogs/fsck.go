@@ -0,0 +1,227 @@
+package txlogs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ProblemKind is a kind of problem found by Check
+type ProblemKind int
+
+// Kinds of problems
+const (
+	// ProblemOrphan is a change to an entry that was deleted or never
+	// added, it can't be applied to the snapshot.
+	ProblemOrphan ProblemKind = iota + 1
+	// ProblemDuplicateAdd is an add of an entry that already exists, it
+	// can't be applied to the snapshot.
+	ProblemDuplicateAdd
+	// ProblemTimeOrder is a transaction with an earlier time than the one
+	// before it. The order of the log comes from the ids so it's harmless
+	// but it means a clock was wrong.
+	ProblemTimeOrder
+	// ProblemStaleSnapshot is a saved snapshot that doesn't match the log,
+	// it's used as is when the file is loaded so it shows the wrong data.
+	ProblemStaleSnapshot
+	// ProblemInvalid is a transaction that can't be applied for any other
+	// reason, a checkpoint with a bad checksum for example.
+	ProblemInvalid
+)
+
+// Problem is something wrong with the log found by Check. Index is the
+// position in the log of the transaction, -1 for the snapshot.
+type Problem struct {
+	Kind   ProblemKind
+	Index  int
+	Tx     Tx
+	Reason string
+}
+
+func (p Problem) Error() string {
+	if p.Index < 0 {
+		return p.Reason
+	}
+	return fmt.Sprintf("transaction %d (%s): %s", p.Index, txID(p.Tx), p.Reason)
+}
+
+// Repairable is true if Repair can fix the problem
+func (p Problem) Repairable() bool {
+	switch p.Kind {
+	case ProblemOrphan, ProblemDuplicateAdd, ProblemStaleSnapshot:
+		return true
+	default:
+		return false
+	}
+}
+
+// Check replays the log looking for problems, transactions that can't be
+// applied are skipped so every problem is found rather than just the first.
+// See Verify for checking the hash chain.
+func (s *DB) Check() []Problem {
+	var problems []Problem
+	snap := make(map[string]Entry)
+	deleted := make(map[string]struct{})
+
+	var saved map[string]Entry
+	for i, tx := range s.Log {
+		if uint(i) == s.Version {
+			saved = copySnapshot(snap)
+		}
+
+		if i > 0 && tx.Time < s.Log[i-1].Time {
+			problems = append(problems, Problem{
+				Kind: ProblemTimeOrder, Index: i, Tx: tx,
+				Reason: "happened before the transaction before it",
+			})
+		}
+
+		_, exists := snap[tx.UUID]
+		switch {
+		case tx.Kind == TxAdd && exists:
+			problems = append(problems, Problem{
+				Kind: ProblemDuplicateAdd, Index: i, Tx: tx,
+				Reason: fmt.Sprintf("adds %s which already exists", tx.UUID),
+			})
+			continue
+		case isEntryChange(tx.Kind) && !exists:
+			reason := "changes %s which was never added"
+			if _, ok := deleted[tx.UUID]; ok {
+				reason = "changes %s after it was deleted"
+			}
+			problems = append(problems, Problem{
+				Kind: ProblemOrphan, Index: i, Tx: tx,
+				Reason: fmt.Sprintf(reason, tx.UUID),
+			})
+			continue
+		}
+
+		if err := applyTx(snap, tx); err != nil {
+			problems = append(problems, Problem{
+				Kind: ProblemInvalid, Index: i, Tx: tx, Reason: err.Error(),
+			})
+			continue
+		}
+		if tx.Kind == TxDelete {
+			deleted[tx.UUID] = struct{}{}
+		}
+	}
+	if s.Version == uint(len(s.Log)) {
+		saved = snap
+	}
+
+	// The saved snapshot is only compared if there is one, an empty file
+	// or one that's never been saved has nothing to go stale
+	if s.Version != 0 {
+		if s.Version > uint(len(s.Log)) {
+			problems = append(problems, Problem{
+				Kind: ProblemStaleSnapshot, Index: -1,
+				Reason: fmt.Sprintf("snapshot is of version %d but there are only %d transactions", s.Version, len(s.Log)),
+			})
+		} else if !reflect.DeepEqual(saved, s.Snapshot) {
+			problems = append(problems, Problem{
+				Kind: ProblemStaleSnapshot, Index: -1,
+				Reason: fmt.Sprintf("snapshot does not match the first %d transactions", s.Version),
+			})
+		}
+	}
+
+	return problems
+}
+
+// Repair fixes the repairable problems that Check found. Orphans and
+// duplicate adds are removed from the log, they can't be applied so the
+// data is the same without them, and the log is chained again after the
+// first one removed. A stale snapshot is thrown away to be rebuilt.
+//
+// A repair transaction listing the ids of the removed transactions is added
+// to the log so that Merge removes them from other copies of the log too,
+// otherwise merging with a copy that still has them would bring them back.
+func (s *DB) Repair(problems []Problem) (repaired int) {
+	remove := make(map[string]struct{})
+	for _, p := range problems {
+		switch p.Kind {
+		case ProblemOrphan, ProblemDuplicateAdd:
+			remove[txID(p.Tx)] = struct{}{}
+		case ProblemStaleSnapshot:
+			s.ResetSnapshot()
+			repaired++
+		}
+	}
+	if len(remove) == 0 {
+		return repaired
+	}
+
+	var ids []string
+	for _, tx := range s.Log {
+		if _, ok := remove[txID(tx)]; ok {
+			ids = append(ids, txID(tx))
+		}
+	}
+	if len(ids) == 0 {
+		return repaired
+	}
+
+	log, first := unrepaired(s.Log, remove)
+	chain(log, first)
+	s.ReplaceLog(log)
+	s.appendLog(Tx{
+		Kind:  TxRepair,
+		Value: strings.Join(ids, ","),
+	})
+
+	return repaired + len(ids)
+}
+
+// repairTargets returns the ids of the transactions the repairs in the logs
+// removed
+func repairTargets(logs ...[]Tx) map[string]struct{} {
+	targets := make(map[string]struct{})
+	for _, log := range logs {
+		for _, tx := range log {
+			if tx.Kind != TxRepair {
+				continue
+			}
+			for _, id := range strings.Split(tx.Value, ",") {
+				targets[id] = struct{}{}
+			}
+		}
+	}
+	return targets
+}
+
+// unrepaired returns a copy of the log without the transactions in remove
+// and the index of the first one that was removed, the links after it are
+// not fixed. If nothing was removed the log is returned as is and first is
+// its length.
+func unrepaired(log []Tx, remove map[string]struct{}) (out []Tx, first int) {
+	first = len(log)
+	for i, tx := range log {
+		if _, ok := remove[txID(tx)]; ok {
+			first = i
+			break
+		}
+	}
+	if first == len(log) {
+		return log, first
+	}
+
+	out = make([]Tx, first, len(log))
+	copy(out, log[:first])
+	for _, tx := range log[first:] {
+		if _, ok := remove[txID(tx)]; !ok {
+			out = append(out, tx)
+		}
+	}
+	return out, first
+}
+
+// isEntryChange is true for kinds that change an entry that must exist
+func isEntryChange(kind TxKind) bool {
+	switch kind {
+	case TxDelete, TxSetKey, TxDeleteKey, TxAddItem, TxDeleteItem:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,131 @@
+package txlogs
+
+import "testing"
+
+func TestCheckRepair(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "b")
+	store.Delete(uuid)
+	other, err := store.Add()
+	must(t, err)
+	store.Set(other, "a", "b")
+	must(t, store.UpdateSnapshot())
+
+	if problems := store.Check(); len(problems) != 0 {
+		t.Fatalf("there should be no problems: %v", problems)
+	}
+
+	// Break it in every way
+	orphan := store.Log[1]
+	orphan.ID = idAfter(txID(store.Log[2]), txID(orphan))
+	orphan.Time = store.Log[2].Time
+	dupe := store.Log[3]
+	dupe.ID = idAfter(txID(store.Log[3]), txID(dupe))
+	store.Log = append(store.Log[:4], append([]Tx{dupe}, store.Log[4:]...)...)
+	store.Log = append(store.Log[:3], append([]Tx{orphan}, store.Log[3:]...)...)
+	store.Log[len(store.Log)-1].Time = 0
+	chain(store.Log, 1)
+	store.Snapshot[other]["a"] = "stale"
+
+	problems := store.Check()
+	kinds := make(map[ProblemKind]int)
+	for _, p := range problems {
+		kinds[p.Kind]++
+	}
+	want := map[ProblemKind]int{
+		ProblemOrphan:        1,
+		ProblemDuplicateAdd:  1,
+		ProblemTimeOrder:     1,
+		ProblemStaleSnapshot: 1,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("problems were wrong: %v", problems)
+	}
+	for k, n := range want {
+		if kinds[k] != n {
+			t.Errorf("should have %d of kind %d: %v", n, k, problems)
+		}
+	}
+
+	if repaired := store.Repair(problems); repaired != 3 {
+		t.Error("should have repaired 3:", repaired)
+	}
+	must(t, store.Verify())
+	must(t, store.UpdateSnapshot())
+
+	problems = store.Check()
+	if len(problems) != 1 || problems[0].Kind != ProblemTimeOrder || problems[0].Repairable() {
+		t.Errorf("only the time order problem should be left: %v", problems)
+	}
+	if len(store.Log) != 6 || store.Snapshot[other]["a"] != "b" {
+		t.Error("log or snapshot were wrong")
+	}
+	if last := store.Log[5]; last.Kind != TxRepair || last.Value != txID(orphan)+","+txID(dupe) {
+		t.Errorf("the repair should be recorded: %#v", last)
+	}
+}
+
+func TestRepairMerge(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "b")
+	store.Delete(uuid)
+	other, err := store.Add()
+	must(t, err)
+
+	// Both copies have the orphan
+	orphan := store.Log[1]
+	orphan.ID = idAfter(txID(store.Log[2]), txID(orphan))
+	store.Log = append(store.Log[:3], append([]Tx{orphan}, store.Log[3:]...)...)
+	chain(store.Log, 1)
+	store.ReplaceLog(store.Log)
+	remote := append([]Tx(nil), store.Log...)
+
+	if repaired := store.Repair(store.Check()); repaired != 1 {
+		t.Fatal("should have repaired 1:", repaired)
+	}
+
+	hasOrphan := func(log []Tx) bool {
+		for _, tx := range log {
+			if txID(tx) == txID(orphan) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The remote hasn't changed since
+	for _, merged := range [][]Tx{
+		mustMerge(t, store.Log, remote),
+		mustMerge(t, remote, store.Log),
+	} {
+		if hasOrphan(merged) || len(merged) != len(store.Log) || head(merged) != head(store.Log) {
+			t.Errorf("merge should be the repaired log: %#v", merged)
+		}
+	}
+
+	// The remote has changed since, the repair still applies
+	remoteDB := &DB{Log: remote}
+	remoteDB.Set(other, "c", "d")
+	for _, merged := range [][]Tx{
+		mustMerge(t, store.Log, remoteDB.Log),
+		mustMerge(t, remoteDB.Log, store.Log),
+	} {
+		if hasOrphan(merged) || len(merged) != len(store.Log)+1 {
+			t.Errorf("merge should not have the orphan: %#v", merged)
+		}
+
+		db := &DB{Log: merged}
+		must(t, db.Verify())
+		if problems := db.Check(); len(problems) != 0 {
+			t.Error("merge should have no problems:", problems)
+		}
+	}
+}
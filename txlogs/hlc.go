@@ -0,0 +1,135 @@
+package txlogs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transaction IDs are hybrid logical clock timestamps. They're made up of
+// the wall clock time, a logical counter that is used when the wall clock
+// has not moved forward (or moved backwards) since the last id was issued,
+// and a random node id to break ties between machines.
+//
+// The fields are fixed width hex so comparing two ids as strings orders
+// them the same way the clock does:
+//
+//	0000016e0a1e1b40.00000000.9f3c01aa
+//
+// Logs that were written before ids existed only have a time, they're
+// given ids from that time on load with the legacy node id and a counter
+// to keep them unique. This is deterministic so two copies of the same old
+// log end up with the same ids.
+const (
+	idSep      = "."
+	legacyNode = "00000000"
+)
+
+// clock is a hybrid logical clock
+type clock struct {
+	wall    int64
+	logical uint32
+	node    string
+}
+
+// next returns a new id that is greater than any id the clock has issued
+// or observed.
+func (c *clock) next() string {
+	if len(c.node) == 0 {
+		c.node = newNode()
+	}
+
+	now := time.Now().UnixNano()
+	if now > c.wall {
+		c.wall = now
+		c.logical = 0
+	} else {
+		c.logical++
+	}
+
+	return formatID(c.wall, c.logical, c.node)
+}
+
+// observe moves the clock forward so the next id it issues is greater than
+// the one given.
+func (c *clock) observe(id string) {
+	wall, logical, ok := parseID(id)
+	if !ok {
+		return
+	}
+
+	if wall > c.wall || (wall == c.wall && logical > c.logical) {
+		c.wall = wall
+		c.logical = logical
+	}
+}
+
+func formatID(wall int64, logical uint32, node string) string {
+	return fmt.Sprintf("%016x%s%08x%s%s", uint64(wall), idSep, logical, idSep, node)
+}
+
+func parseID(id string) (wall int64, logical uint32, ok bool) {
+	parts := strings.Split(id, idSep)
+	if len(parts) != 3 {
+		return 0, 0, false
+	}
+
+	w, err := strconv.ParseUint(parts[0], 16, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	l, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return int64(w), uint32(l), true
+}
+
+// idAfter returns an id that sorts directly after id, taking the node from
+// another id.
+func idAfter(id, nodeOf string) string {
+	wall, logical, _ := parseID(id)
+
+	node := legacyNode
+	if parts := strings.Split(nodeOf, idSep); len(parts) == 3 {
+		node = parts[2]
+	}
+
+	return formatID(wall, logical+1, node)
+}
+
+func newNode() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// txID returns the id of the transaction, transactions without one
+// (not yet migrated) use their time.
+func txID(tx Tx) string {
+	if len(tx.ID) != 0 {
+		return tx.ID
+	}
+	return formatID(tx.Time, 0, legacyNode)
+}
+
+// migrateIDs gives ids to transactions that were created before they
+// existed.
+func migrateIDs(log []Tx) {
+	seen := make(map[int64]uint32)
+	for i := range log {
+		if len(log[i].ID) != 0 {
+			continue
+		}
+
+		n := seen[log[i].Time]
+		seen[log[i].Time] = n + 1
+		log[i].ID = formatID(log[i].Time, n, legacyNode)
+	}
+}
@@ -0,0 +1,109 @@
+package txlogs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	var c clock
+	a := c.next()
+	b := c.next()
+	if a >= b {
+		t.Errorf("ids should increase: %s %s", a, b)
+	}
+
+	// Pretend a machine with a clock far in the future sent us something
+	future := formatID(time.Now().Add(time.Hour).UnixNano(), 5, legacyNode)
+	c.observe(future)
+
+	d := c.next()
+	if d <= future {
+		t.Errorf("id should be after the observed one: %s %s", future, d)
+	}
+	e := c.next()
+	if e <= d {
+		t.Errorf("ids should increase even when the wall clock is behind: %s %s", d, e)
+	}
+
+	wall, logical, ok := parseID(e)
+	if !ok {
+		t.Fatal("failed to parse id:", e)
+	}
+	if wall != c.wall || logical != 7 {
+		t.Error("parsed wrong values:", wall, logical)
+	}
+}
+
+func TestMigrateIDs(t *testing.T) {
+	t.Parallel()
+
+	log := []Tx{
+		{Time: 1, Kind: TxAdd, UUID: "1"},
+		{Time: 1, Kind: TxAdd, UUID: "2"},
+		{Time: 2, Kind: TxSetKey, UUID: "1", Key: "a", Value: "b"},
+	}
+	again := make([]Tx, len(log))
+	copy(again, log)
+
+	migrateIDs(log)
+	migrateIDs(again)
+
+	if log[0].ID == log[1].ID {
+		t.Error("ids with the same time should be unique")
+	}
+	if log[0].ID >= log[1].ID || log[1].ID >= log[2].ID {
+		t.Error("ids should be ordered")
+	}
+	if !reflect.DeepEqual(log, again) {
+		t.Error("migration should be deterministic")
+	}
+}
+
+func TestMergeSameTime(t *testing.T) {
+	t.Parallel()
+
+	logA := []Tx{
+		{ID: formatID(1, 0, "aaaaaaaa"), Time: 1, Kind: TxAdd, UUID: "1"},
+		{ID: formatID(2, 0, "aaaaaaaa"), Time: 2, Kind: TxAdd, UUID: "2"},
+	}
+	logB := []Tx{
+		{ID: formatID(1, 0, "aaaaaaaa"), Time: 1, Kind: TxAdd, UUID: "1"},
+		{ID: formatID(2, 0, "bbbbbbbb"), Time: 2, Kind: TxAdd, UUID: "3"},
+	}
+
+	merged, conflicts := Merge(logA, logB, nil)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts should be empty: %#v", conflicts)
+	}
+
+	want := []Tx{logA[0], logA[1], logB[1]}
+	if !reflect.DeepEqual(want, merged) {
+		t.Errorf("merged differs: %#v", merged)
+	}
+}
+
+func TestRollbackByID(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+
+	store.Begin()
+	store.Set(uuid, "a", "b")
+
+	// Something else rewrote the log in front of our savepoint
+	store.Log = append([]Tx{{ID: formatID(0, 0, legacyNode), Kind: TxAdd, UUID: "0"}}, store.Log...)
+
+	store.Rollback()
+	if len(store.Log) != 2 {
+		t.Error("it should have rolled back to the savepoint, len:", len(store.Log))
+	}
+	if store.Log[1].UUID != uuid {
+		t.Error("the wrong tx was rolled back")
+	}
+}
@@ -0,0 +1,128 @@
+package txlogs
+
+// index is where in the log each entry's transactions are so that the
+// history of one entry can be found without scanning the whole log.
+//
+// It's kept up to date as the log is changed by the DB's methods so that
+// reading it never changes the DB. The log is exported and can be changed
+// directly though, so the index remembers how much of the log it covers and
+// the id of the last transaction it indexed. If that transaction is no
+// longer where it was the log has changed underneath it and it's not used
+// until the next change rebuilds it.
+type index struct {
+	n    int
+	head string
+	txs  map[string][]int
+}
+
+// entryTxs returns the positions in the log of the transactions that touch
+// the entry, oldest first. The slice belongs to the index.
+func (s *DB) entryTxs(uuid string) []int {
+	if s.indexCurrent() {
+		return s.index.txs[uuid]
+	}
+
+	var positions []int
+	for i, tx := range s.Log {
+		for _, u := range touched(tx) {
+			if u == uuid {
+				positions = append(positions, i)
+				break
+			}
+		}
+	}
+	return positions
+}
+
+// indexCurrent checks that the index covers the whole log as it is
+func (s *DB) indexCurrent() bool {
+	n := s.index.n
+	return n == len(s.Log) && (n == 0 || txID(s.Log[n-1]) == s.index.head)
+}
+
+// updateIndex indexes the transactions added to the log since it was last
+// called, or all of them if the log has been changed in some other way.
+func (s *DB) updateIndex() {
+	n := s.index.n
+	if n > len(s.Log) || (n != 0 && txID(s.Log[n-1]) != s.index.head) {
+		s.index = index{}
+	}
+	if s.index.txs == nil {
+		s.index.txs = make(map[string][]int)
+	}
+
+	for ; s.index.n < len(s.Log); s.index.n++ {
+		for _, uuid := range touched(s.Log[s.index.n]) {
+			s.index.txs[uuid] = append(s.index.txs[uuid], s.index.n)
+		}
+	}
+
+	if s.index.n != 0 {
+		s.index.head = txID(s.Log[s.index.n-1])
+	}
+}
+
+// ResetIndex rebuilds the index. The index notices most changes to the log
+// on its own but a log that was set directly may have transactions inserted
+// and removed before the end of what was indexed.
+func (s *DB) ResetIndex() {
+	s.index = index{}
+	s.updateIndex()
+}
+
+// indexAppended indexes the transactions that were just appended to the log
+func (s *DB) indexAppended() {
+	s.updateIndex()
+}
+
+// truncateIndex removes the transactions that are being cut off the end of
+// the log from the index, n is the new length of the log.
+func (s *DB) truncateIndex(n int) {
+	if s.index.n <= n {
+		return
+	}
+	if s.index.n > len(s.Log) || txID(s.Log[s.index.n-1]) != s.index.head {
+		// Already out of date, it's rebuilt after the log is cut
+		return
+	}
+
+	for i := s.index.n - 1; i >= n; i-- {
+		for _, uuid := range touched(s.Log[i]) {
+			positions := s.index.txs[uuid]
+			if len(positions) == 1 {
+				delete(s.index.txs, uuid)
+			} else {
+				s.index.txs[uuid] = positions[:len(positions)-1]
+			}
+		}
+	}
+
+	s.index.n = n
+	s.index.head = ""
+	if n != 0 {
+		s.index.head = txID(s.Log[n-1])
+	}
+}
+
+// touched returns the uuids of the entries a transaction has anything to do
+// with, a checkpoint has every entry that was in it.
+func touched(tx Tx) []string {
+	switch tx.Kind {
+	case TxCheckpoint:
+		snap, err := checkpointSnapshot(tx)
+		if err != nil {
+			return nil
+		}
+
+		uuids := make([]string, 0, len(snap))
+		for uuid := range snap {
+			uuids = append(uuids, uuid)
+		}
+		return uuids
+	case TxPurge, TxRepair:
+		// Purges and repairs don't change the entry
+		return nil
+	default:
+		return []string{tx.UUID}
+	}
+}
@@ -0,0 +1,125 @@
+package txlogs
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	a, err := store.Add()
+	must(t, err)
+	b, err := store.Add()
+	must(t, err)
+	store.Set(a, "k", "1")
+	store.Set(b, "k", "1")
+	store.Set(a, "k", "2")
+
+	check := func(name string) {
+		t.Helper()
+		for _, uuid := range []string{a, b} {
+			var want []int
+			for i, tx := range store.Log {
+				if tx.UUID == uuid {
+					want = append(want, i)
+				}
+			}
+			if got := store.entryTxs(uuid); !reflect.DeepEqual(want, got) {
+				t.Errorf("%s: %s index was wrong, want: %v got: %v", name, uuid, want, got)
+			}
+		}
+	}
+
+	check("built")
+	if n := store.NVersions(a); n != 3 {
+		t.Error("versions was wrong:", n)
+	}
+	if last := store.LastUpdated(b); last != store.Log[3].Time {
+		t.Error("last updated was wrong:", last)
+	}
+
+	store.Set(b, "k", "2")
+	check("appended")
+
+	must(t, store.RollbackN(2))
+	check("rolled back")
+
+	store.Begin()
+	store.Set(b, "k", "3")
+	store.Rollback()
+	check("savepoint")
+
+	// Replaced with a log that differs before the end of the index
+	other := &DB{Log: append([]Tx(nil), store.Log[:2]...)}
+	other.Set(b, "j", "4")
+	store.Log = mustMerge(t, store.Log, other.Log)
+	check("merged")
+
+	if n := store.NVersions("nope"); n != 0 {
+		t.Error("versions was wrong:", n)
+	}
+	if last := store.LastUpdated("nope"); last != -1 {
+		t.Error("last updated was wrong:", last)
+	}
+}
+
+// longStore has 100k transactions spread over 1000 entries
+func longStore() (*DB, []string) {
+	s := new(DB)
+	uuids := make([]string, 1000)
+	for i := range uuids {
+		uuid, err := s.Add()
+		if err != nil {
+			panic(err)
+		}
+		uuids[i] = uuid
+	}
+
+	for i := len(s.Log); i < 100000; i++ {
+		s.Set(uuids[i%len(uuids)], "key"+strconv.Itoa(i%7), strconv.Itoa(i))
+	}
+
+	return s, uuids
+}
+
+func BenchmarkIndexBuild(b *testing.B) {
+	s, _ := longStore()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.ResetIndex()
+		s.updateIndex()
+	}
+}
+
+func BenchmarkNVersions(b *testing.B) {
+	s, uuids := longStore()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.NVersions(uuids[i%len(uuids)])
+	}
+}
+
+func BenchmarkLastUpdated(b *testing.B) {
+	s, uuids := longStore()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.LastUpdated(uuids[i%len(uuids)])
+	}
+}
+
+func BenchmarkEntrySnapshotAt(b *testing.B) {
+	s, uuids := longStore()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := s.EntrySnapshotAt(uuids[i%len(uuids)], 5); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package txlogs
+
+import (
+	"sort"
+	"strings"
+)
+
+// Lists are keys whose value is made up of items that are added and removed
+// one at a time instead of being set as a whole. This lets two copies that
+// changed the same list be merged without one overwriting the other.
+//
+// Each item is identified by the id of the transaction that added it and is
+// kept in the entry under a hidden key (the list key, itemSep, the item id).
+// The list key itself holds the comma separated values of the items so that
+// it can still be read like any other key.
+//
+// A list key that was set as a whole (as all keys were before lists
+// existed) is turned into items the first time one is added or removed. The
+// items are given ids from their position so that every copy converts it
+// the same way.
+const itemSep = "\x1f"
+
+// Item in a list
+type Item struct {
+	ID    string
+	Value string
+}
+
+// AddItem adds an item to a list on an entry and returns the item's id
+func (s *DB) AddItem(uuid, key, value string) (id string) {
+	s.appendLog(
+		Tx{
+			Kind:  TxAddItem,
+			UUID:  uuid,
+			Key:   key,
+			Value: value,
+		},
+	)
+
+	return s.Log[len(s.Log)-1].ID
+}
+
+// DeleteItem removes an item from a list on an entry
+func (s *DB) DeleteItem(uuid, key, id string) {
+	s.appendLog(
+		Tx{
+			Kind:  TxDeleteItem,
+			UUID:  uuid,
+			Key:   key,
+			Index: id,
+		},
+	)
+}
+
+// Items returns the items of a list in order. If the key was set as a whole
+// it's split on commas and the items have the ids they'll be given when the
+// list is first changed.
+func (e Entry) Items(key string) []Item {
+	if items := listItems(e, key); len(items) != 0 {
+		return items
+	}
+
+	return legacyItems(e[key])
+}
+
+// listItems returns the items kept under hidden keys in order
+func listItems(entry Entry, key string) []Item {
+	prefix := key + itemSep
+
+	var items []Item
+	for k, v := range entry {
+		if strings.HasPrefix(k, prefix) {
+			items = append(items, Item{ID: k[len(prefix):], Value: v})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// IsItemKey checks if the key is one of the hidden keys holding the items
+// of a list
+func IsItemKey(key string) bool {
+	return strings.Contains(key, itemSep)
+}
+
+func itemKey(key, id string) string {
+	return key + itemSep + id
+}
+
+func legacyItems(value string) []Item {
+	if len(value) == 0 {
+		return nil
+	}
+
+	values := strings.Split(value, ",")
+	items := make([]Item, len(values))
+	for i, v := range values {
+		items[i] = Item{ID: formatID(0, uint32(i), legacyNode), Value: v}
+	}
+	return items
+}
+
+// convertList turns a key that was set as a whole into list items
+func convertList(entry Entry, key string) {
+	prefix := key + itemSep
+	for k := range entry {
+		if strings.HasPrefix(k, prefix) {
+			return
+		}
+	}
+
+	for _, item := range legacyItems(entry[key]) {
+		entry[itemKey(key, item.ID)] = item.Value
+	}
+}
+
+// joinList updates the list key's value from its items, values that were
+// added more than once (by different copies) are only shown once.
+func joinList(entry Entry, key string) {
+	items := listItems(entry, key)
+	if len(items) == 0 {
+		delete(entry, key)
+		return
+	}
+
+	seen := make(map[string]struct{}, len(items))
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := seen[item.Value]; ok {
+			continue
+		}
+		seen[item.Value] = struct{}{}
+		values = append(values, item.Value)
+	}
+
+	entry[key] = strings.Join(values, ",")
+}
+
+// clearList removes all items of a list, used when the key is set or
+// deleted as a whole
+func clearList(entry Entry, key string) {
+	prefix := key + itemSep
+	for k := range entry {
+		if strings.HasPrefix(k, prefix) {
+			delete(entry, k)
+		}
+	}
+}
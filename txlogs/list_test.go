@@ -0,0 +1,98 @@
+package txlogs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+
+	store.AddItem(uuid, "labels", "a")
+	id := store.AddItem(uuid, "labels", "b")
+	store.AddItem(uuid, "labels", "c")
+	store.DeleteItem(uuid, "labels", id)
+	must(t, store.UpdateSnapshot())
+
+	entry := store.Snapshot[uuid]
+	if got := entry["labels"]; got != "a,c" {
+		t.Error("value was wrong:", got)
+	}
+
+	items := entry.Items("labels")
+	if len(items) != 2 || items[0].Value != "a" || items[1].Value != "c" {
+		t.Errorf("items were wrong: %#v", items)
+	}
+
+	for _, item := range items {
+		store.DeleteItem(uuid, "labels", item.ID)
+	}
+	must(t, store.UpdateSnapshot())
+
+	if len(store.Snapshot[uuid]) != 0 {
+		t.Errorf("entry should be empty: %#v", store.Snapshot[uuid])
+	}
+}
+
+func TestListLegacy(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+
+	store.Set(uuid, "labels", "a,b")
+	must(t, store.UpdateSnapshot())
+
+	items := store.Snapshot[uuid].Items("labels")
+	if len(items) != 2 {
+		t.Fatalf("items were wrong: %#v", items)
+	}
+
+	store.DeleteItem(uuid, "labels", items[0].ID)
+	store.AddItem(uuid, "labels", "c")
+	must(t, store.UpdateSnapshot())
+
+	if got := store.Snapshot[uuid]["labels"]; got != "b,c" {
+		t.Error("value was wrong:", got)
+	}
+
+	// Setting the whole value replaces the items
+	store.Set(uuid, "labels", "d")
+	must(t, store.UpdateSnapshot())
+
+	want := Entry{"labels": "d"}
+	if !reflect.DeepEqual(want, store.Snapshot[uuid]) {
+		t.Errorf("entry was wrong: %#v", store.Snapshot[uuid])
+	}
+}
+
+func TestListMerge(t *testing.T) {
+	t.Parallel()
+
+	a := new(DB)
+	uuid, err := a.Add()
+	must(t, err)
+	id := a.AddItem(uuid, "labels", "shared")
+
+	b := &DB{Log: make([]Tx, len(a.Log))}
+	copy(b.Log, a.Log)
+
+	a.AddItem(uuid, "labels", "a")
+	a.DeleteItem(uuid, "labels", id)
+	b.AddItem(uuid, "labels", "b")
+	b.AddItem(uuid, "labels", "a")
+	b.DeleteItem(uuid, "labels", id)
+
+	merged := mustMerge(t, a.Log, b.Log)
+	store := &DB{Log: merged}
+	must(t, store.UpdateSnapshot())
+
+	if got := store.Snapshot[uuid]["labels"]; got != "a,b" {
+		t.Error("value was wrong:", got)
+	}
+}
@@ -0,0 +1,111 @@
+package txlogs
+
+// Change is a change to the log, Removed are transactions that were taken
+// off the end of the log and Appended are the ones added to the end after
+// that. When the log is replaced (see ReplaceLog) everything after the
+// history the old and new logs share is removed and appended.
+//
+// The transactions are copies, they can be kept.
+type Change struct {
+	Removed  []Tx
+	Appended []Tx
+}
+
+// subscriber is a function registered with Subscribe
+type subscriber struct {
+	id int
+	fn func(Change)
+}
+
+// Subscribe calls fn after each change to the log made through the DB's
+// methods, it's called with changes made inside a transaction as they
+// happen and again with what's removed if it's rolled back. Changing the
+// Log field directly isn't noticed.
+//
+// fn must not change the DB. The returned function stops the calls.
+func (s *DB) Subscribe(fn func(Change)) (unsubscribe func()) {
+	s.lastSubscriber++
+	id := s.lastSubscriber
+	s.subscribers = append(s.subscribers, subscriber{id: id, fn: fn})
+
+	return func() {
+		for i, sub := range s.subscribers {
+			if sub.id == id {
+				s.subscribers = append(s.subscribers[:i:i], s.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// ReplaceLog replaces the log with another version of it (a merge for
+// example). The snapshot is thrown away and the index and snapshot points
+// are rebuilt since the new log may have been changed anywhere, even without
+// changing the hashes if it was purged.
+func (s *DB) ReplaceLog(log []Tx) {
+	shared := 0
+	for shared < len(s.Log) && shared < len(log) && txID(s.Log[shared]) == txID(log[shared]) {
+		shared++
+	}
+
+	var change Change
+	if len(s.subscribers) != 0 {
+		change.Removed = copyTxs(s.Log[shared:])
+		change.Appended = copyTxs(log[shared:])
+	}
+
+	s.ResetSnapshot()
+	s.Log = log
+	s.ResetIndex()
+	s.ResetPoints()
+
+	s.notify(change)
+}
+
+// Append transactions that were made before (and undone with RollbackN for
+// example) to the end of the log as they are. They must follow on from the
+// end of the log.
+func (s *DB) Append(txs ...Tx) {
+	s.Log = append(s.Log, txs...)
+	s.indexAppended()
+	s.pointsAppended()
+
+	if len(s.subscribers) != 0 {
+		s.notify(Change{Appended: copyTxs(txs)})
+	}
+}
+
+// truncate cuts the log down to the first n transactions
+func (s *DB) truncate(n int) {
+	var removed []Tx
+	if len(s.subscribers) != 0 {
+		removed = copyTxs(s.Log[n:])
+	}
+
+	s.truncateIndex(n)
+	s.truncatePoints(n)
+	s.Log = s.Log[:n]
+	s.updateIndex()
+
+	s.notify(Change{Removed: removed})
+}
+
+// notify the subscribers of a change if something changed
+func (s *DB) notify(change Change) {
+	if len(change.Removed) == 0 && len(change.Appended) == 0 {
+		return
+	}
+
+	for _, sub := range s.subscribers {
+		sub.fn(change)
+	}
+}
+
+func copyTxs(txs []Tx) []Tx {
+	if len(txs) == 0 {
+		return nil
+	}
+	cpy := make([]Tx, len(txs))
+	copy(cpy, txs)
+	return cpy
+}
@@ -0,0 +1,84 @@
+package txlogs
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	db := new(DB)
+	var changes []Change
+	unsubscribe := db.Subscribe(func(c Change) {
+		changes = append(changes, c)
+	})
+
+	// last returns the change made since it was last called
+	last := func(t *testing.T) Change {
+		t.Helper()
+		if len(changes) != 1 {
+			t.Fatalf("there should be one change: %#v", changes)
+		}
+		c := changes[0]
+		changes = nil
+		return c
+	}
+
+	uuid, err := db.Add()
+	must(t, err)
+	if c := last(t); len(c.Appended) != 1 || c.Appended[0].UUID != uuid || len(c.Removed) != 0 {
+		t.Errorf("add was wrong: %#v", c)
+	}
+
+	db.Set(uuid, "name", "value")
+	if c := last(t); len(c.Appended) != 1 || c.Appended[0].Value != "value" {
+		t.Errorf("set was wrong: %#v", c)
+	}
+
+	db.Begin()
+	db.Set(uuid, "a", "a")
+	db.Set(uuid, "b", "b")
+	changes = nil
+	db.Rollback()
+	if c := last(t); len(c.Removed) != 2 || c.Removed[0].Key != "a" || c.Removed[1].Key != "b" {
+		t.Errorf("rollback was wrong: %#v", c)
+	}
+
+	db.Set(uuid, "c", "c")
+	changes = nil
+	must(t, db.RollbackN(1))
+	c := last(t)
+	if len(c.Removed) != 1 || c.Removed[0].Key != "c" {
+		t.Errorf("rollbackn was wrong: %#v", c)
+	}
+
+	db.Append(c.Removed...)
+	if c := last(t); len(c.Appended) != 1 || c.Appended[0].Key != "c" {
+		t.Errorf("append was wrong: %#v", c)
+	}
+
+	// Replace the last transaction with a fork of the log
+	fork := &DB{Log: make([]Tx, 2)}
+	copy(fork.Log, db.Log)
+	fork.Set(uuid, "d", "d")
+	db.ReplaceLog(fork.Log)
+	if c := last(t); len(c.Removed) != 1 || c.Removed[0].Key != "c" ||
+		len(c.Appended) != 1 || c.Appended[0].Key != "d" {
+		t.Errorf("replace was wrong: %#v", c)
+	}
+	must(t, db.UpdateSnapshot())
+	if _, ok := db.Snapshot[uuid]["c"]; ok {
+		t.Error("snapshot should have been rebuilt")
+	}
+
+	// Nothing changes so nothing is sent
+	db.ReplaceLog(fork.Log)
+	must(t, db.RollbackN(0))
+	if len(changes) != 0 {
+		t.Errorf("there should be no changes: %#v", changes)
+	}
+
+	unsubscribe()
+	db.Set(uuid, "name", "value2")
+	if len(changes) != 0 {
+		t.Errorf("there should be no changes after unsubscribing: %#v", changes)
+	}
+}
@@ -0,0 +1,151 @@
+package txlogs
+
+// defaultPointInterval is how many transactions apart snapshot points are
+const defaultPointInterval = 1000
+
+// SnapshotPoint is a snapshot of every entry after the first Version
+// transactions of the log. Points are made every so many transactions as the
+// log is changed so that queries about the past can start from the nearest
+// one instead of the start of the log.
+//
+// Head is the hash of the last transaction the point covers, a point is only
+// used if the transaction at that position still has that hash. The hash
+// chain means any change to the history before it is noticed.
+type SnapshotPoint struct {
+	Version  int              `msgpack:"version" json:"version"`
+	Head     string           `msgpack:"head" json:"head"`
+	Snapshot map[string]Entry `msgpack:"snapshot" json:"snapshot"`
+}
+
+// ResetPoints rebuilds the snapshot points. Purged values are removed from
+// transactions without changing their hashes so this must be done when the
+// log is purged or replaced by a merge that may have purged it.
+func (s *DB) ResetPoints() {
+	s.Points = nil
+	s.updatePoints()
+}
+
+// replay returns a new snapshot of the first n transactions of the log, it
+// doesn't change the DB
+func (s *DB) replay(n int) (map[string]Entry, error) {
+	start := s.nearestPoint(n)
+
+	snap := make(map[string]Entry)
+	version := 0
+	if start >= 0 {
+		snap = copySnapshot(s.Points[start].Snapshot)
+		version = s.Points[start].Version
+	}
+
+	for ; version < n; version++ {
+		if err := applyTx(snap, s.Log[version]); err != nil {
+			return nil, err
+		}
+	}
+
+	return snap, nil
+}
+
+// nearestPoint returns the index of the last valid point at or before
+// version n, -1 if there are none
+func (s *DB) nearestPoint(n int) int {
+	nearest := -1
+	for i, p := range s.Points {
+		if p.Version > n || !s.validPoint(p) {
+			break
+		}
+		nearest = i
+	}
+
+	return nearest
+}
+
+// validPoint checks the point still matches the log
+func (s *DB) validPoint(p SnapshotPoint) bool {
+	return p.Version >= 1 && p.Version <= len(s.Log) && hashTx(s.Log[p.Version-1]) == p.Head
+}
+
+// updatePoints removes the points that no longer match the log along with
+// the ones after them and adds the missing ones up to the end of the log.
+// Points are only there to speed things up so if the log can't be replayed
+// they stop where it fails.
+func (s *DB) updatePoints() {
+	last := s.nearestPoint(len(s.Log))
+	s.Points = s.Points[:last+1]
+
+	interval := s.pointInterval()
+	version := 0
+	if last >= 0 {
+		version = s.Points[last].Version
+	}
+	end := len(s.Log) / interval * interval
+	if version >= end {
+		return
+	}
+
+	snap := make(map[string]Entry)
+	if last >= 0 {
+		snap = copySnapshot(s.Points[last].Snapshot)
+	}
+	for ; version < end; version++ {
+		if err := applyTx(snap, s.Log[version]); err != nil {
+			return
+		}
+
+		if (version+1)%interval == 0 {
+			s.addPoint(version+1, snap)
+		}
+	}
+}
+
+// pointsAppended adds a point if the transactions that were just appended
+// to the log reach the next one
+func (s *DB) pointsAppended() {
+	version := 0
+	if len(s.Points) != 0 {
+		version = s.Points[len(s.Points)-1].Version
+	}
+	if len(s.Log)-version >= s.pointInterval() {
+		s.updatePoints()
+	}
+}
+
+// truncatePoints removes the points after the first n transactions, n is
+// the new length of the log
+func (s *DB) truncatePoints(n int) {
+	for i, p := range s.Points {
+		if p.Version > n {
+			s.Points = s.Points[:i]
+			return
+		}
+	}
+}
+
+// addPoint adds a copy of snap as the point for version if it's after the
+// last point
+func (s *DB) addPoint(version int, snap map[string]Entry) {
+	if len(s.Points) != 0 && s.Points[len(s.Points)-1].Version >= version {
+		return
+	}
+
+	s.Points = append(s.Points, SnapshotPoint{
+		Version:  version,
+		Head:     hashTx(s.Log[version-1]),
+		Snapshot: copySnapshot(snap),
+	})
+}
+
+func (s *DB) pointInterval() int {
+	if s.PointInterval > 0 {
+		return s.PointInterval
+	}
+	return defaultPointInterval
+}
+
+func copySnapshot(snap map[string]Entry) map[string]Entry {
+	cpy := make(map[string]Entry, len(snap))
+	for uuid, entry := range snap {
+		cpy[uuid] = copyEntry(entry)
+	}
+	return cpy
+}
@@ -0,0 +1,176 @@
+package txlogs
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+)
+
+func TestPointsMatchReplay(t *testing.T) {
+	t.Parallel()
+
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		store := &DB{PointInterval: 1 + r.Intn(20)}
+		uuids := randomOps(r, store, 300)
+
+		check := func() bool {
+			for i := 0; i < 20; i++ {
+				n := r.Intn(len(store.Log) + 1)
+				got, err := store.replay(n)
+				if err != nil {
+					t.Log(err)
+					return false
+				}
+				if want := fullReplay(t, store.Log[:n]); !reflect.DeepEqual(want, got) {
+					t.Logf("snapshot after %d was wrong", n)
+					return false
+				}
+
+				uuid := uuids[r.Intn(len(uuids))]
+				ago := r.Intn(store.NVersions(uuid) + 1)
+				got1, err1 := store.EntrySnapshotAt(uuid, ago)
+				want1, err2 := (&DB{Log: store.Log}).EntrySnapshotAt(uuid, ago)
+				if !reflect.DeepEqual(want1, got1) || (err1 == nil) != (err2 == nil) {
+					t.Logf("entry %s %d versions ago was wrong", uuid, ago)
+					return false
+				}
+			}
+			return true
+		}
+
+		if !check() {
+			return false
+		}
+
+		// The points after where the log was changed must not be used
+		must(t, store.RollbackN(uint(r.Intn(len(store.Log)))))
+		uuids = append(uuids, randomOps(r, store, 100)...)
+		return check()
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPointsBuiltOnWrite(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{PointInterval: 3}
+	uuids := randomOps(rand.New(rand.NewSource(1)), store, 50)
+	if want := len(store.Log) / 3; len(store.Points) != want {
+		t.Errorf("want %d points, got %d", want, len(store.Points))
+	}
+
+	// Reading the past must not change anything so that it's safe to do
+	// from many goroutines at once
+	points := append([]SnapshotPoint(nil), store.Points...)
+	idx := store.index
+	for n := 0; n <= len(store.Log); n++ {
+		_, err := store.AtVersion(n)
+		must(t, err)
+	}
+	for _, uuid := range uuids {
+		for ago := 0; ago <= store.NVersions(uuid); ago++ {
+			_, _ = store.EntrySnapshotAt(uuid, ago)
+		}
+	}
+	if !reflect.DeepEqual(points, store.Points) || !reflect.DeepEqual(idx, store.index) {
+		t.Error("reads changed the points or index")
+	}
+
+	must(t, store.RollbackN(10))
+	if want := len(store.Log) / 3; len(store.Points) != want {
+		t.Errorf("want %d points after rollback, got %d", want, len(store.Points))
+	}
+	for _, p := range store.Points {
+		if !store.validPoint(p) {
+			t.Errorf("point %d is not valid", p.Version)
+		}
+	}
+}
+
+func TestPointsPersist(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{PointInterval: 2}
+	randomOps(rand.New(rand.NewSource(1)), store, 10)
+	_, err := store.SnapshotAt(0)
+	must(t, err)
+	if len(store.Points) == 0 {
+		t.Fatal("there should be points")
+	}
+
+	data, err := store.Save()
+	must(t, err)
+	loaded, err := New(data)
+	must(t, err)
+	if len(loaded.Points) != 0 {
+		t.Error("points should not have been saved")
+	}
+
+	store.PersistPoints = true
+	data, err = store.Save()
+	must(t, err)
+	loaded, err = New(data)
+	must(t, err)
+	if !reflect.DeepEqual(store.Points, loaded.Points) {
+		t.Error("points should have been saved")
+	}
+}
+
+// randomOps does n random changes to the store and returns the uuids that
+// were added
+func randomOps(r *rand.Rand, store *DB, n int) []string {
+	var uuids []string
+	for i := 0; i < n; i++ {
+		if len(uuids) == 0 || r.Intn(10) == 0 {
+			uuid, err := store.Add()
+			if err != nil {
+				panic(err)
+			}
+			uuids = append(uuids, uuid)
+			continue
+		}
+
+		uuid := uuids[r.Intn(len(uuids))]
+		if err := store.UpdateSnapshot(); err != nil {
+			panic(err)
+		}
+		entry, ok := store.Snapshot[uuid]
+		if !ok {
+			continue
+		}
+
+		key := "key" + strconv.Itoa(r.Intn(4))
+		switch r.Intn(20) {
+		case 0:
+			store.Delete(uuid)
+		case 1, 2:
+			store.DeleteKey(uuid, key)
+		case 3, 4:
+			store.AddItem(uuid, "list", strconv.Itoa(i))
+		case 5:
+			if items := entry.Items("list"); len(items) != 0 {
+				store.DeleteItem(uuid, "list", items[0].ID)
+			}
+		default:
+			store.Set(uuid, key, strconv.Itoa(i))
+		}
+	}
+
+	return uuids
+}
+
+func fullReplay(t *testing.T, log []Tx) map[string]Entry {
+	t.Helper()
+
+	snap := make(map[string]Entry)
+	for _, tx := range log {
+		must(t, applyTx(snap, tx))
+	}
+	return snap
+}
@@ -0,0 +1,169 @@
+package txlogs
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Purge redacts the old values of a key on an entry, or of all of its keys
+// if key is empty. The values the entry has right now are kept, if the
+// entry (or key) has been deleted every value is redacted. It returns the
+// number of values that were redacted.
+//
+// Redacted sets keep a digest of the value they had so the hash chain is
+// not broken. A purge transaction listing the ids of the redacted
+// transactions is added to the log for each key so that Merge can redact
+// other copies of the log the same way.
+func (s *DB) Purge(uuid, key string) (purged int, err error) {
+	if s.InTransaction() {
+		return 0, errors.New("refusing to purge while transaction active")
+	}
+	if err = s.UpdateSnapshot(); err != nil {
+		return 0, err
+	}
+
+	// Each key's ids in log order, the last one may be the current value
+	ids := make(map[string][]string)
+	for _, tx := range s.Log {
+		switch tx.Kind {
+		case TxSetKey:
+			if tx.UUID != uuid || (len(key) != 0 && tx.Key != key) {
+				continue
+			}
+			ids[tx.Key] = append(ids[tx.Key], txID(tx))
+		case TxCheckpoint:
+			snap, err := checkpointSnapshot(tx)
+			if err != nil {
+				return 0, err
+			}
+			for k := range snap[uuid] {
+				if len(key) == 0 || k == key {
+					ids[k] = append(ids[k], txID(tx))
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(ids))
+	for k := range ids {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	current := s.Snapshot[uuid]
+	for _, k := range keys {
+		list := ids[k]
+		if _, ok := current[k]; ok {
+			list = list[:len(list)-1]
+		}
+		list = unpurged(s.Log, k, list)
+		if len(list) == 0 {
+			continue
+		}
+
+		s.appendLog(Tx{
+			Kind:  TxPurge,
+			UUID:  uuid,
+			Key:   k,
+			Value: strings.Join(list, ","),
+		})
+		purged += len(list)
+	}
+
+	redact(s.Log)
+	s.ResetPoints()
+	return purged, nil
+}
+
+// purgeTargets returns the ids of the transactions the purges in the log
+// list, joined to the key they were purged for with a null byte
+func purgeTargets(log []Tx) map[string]struct{} {
+	targets := make(map[string]struct{})
+	for _, tx := range log {
+		if tx.Kind != TxPurge {
+			continue
+		}
+		for _, id := range strings.Split(tx.Value, ",") {
+			targets[id+"\x00"+tx.Key] = struct{}{}
+		}
+	}
+	return targets
+}
+
+// unpurged filters out ids that have already been purged for the key
+func unpurged(log []Tx, key string, ids []string) []string {
+	done := purgeTargets(log)
+
+	var out []string
+	for _, id := range ids {
+		if _, ok := done[id+"\x00"+key]; ok {
+			continue
+		}
+		out = append(out, id)
+	}
+	return out
+}
+
+// redact applies the purge transactions in the log to the transactions
+// they list
+func redact(log []Tx) {
+	targets := make(map[string][]Tx)
+	for _, tx := range log {
+		if tx.Kind != TxPurge {
+			continue
+		}
+		for _, id := range strings.Split(tx.Value, ",") {
+			targets[id] = append(targets[id], tx)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	for i := range log {
+		purges, ok := targets[txID(log[i])]
+		if !ok {
+			continue
+		}
+
+		switch log[i].Kind {
+		case TxSetKey:
+			if len(log[i].Purged) != 0 {
+				continue
+			}
+			log[i].Purged = valueDigest(log[i])
+			log[i].Value = ""
+			log[i].Salt = ""
+		case TxCheckpoint:
+			for _, p := range purges {
+				redactCheckpoint(&log[i], p.UUID, p.Key)
+			}
+		}
+	}
+}
+
+// redactCheckpoint clears a key of an entry in the checkpoint's snapshot and
+// updates its checksum. The checkpoint's place in the chain does not depend
+// on its value so it's unaffected.
+func redactCheckpoint(tx *Tx, uuid, key string) {
+	snap, err := checkpointSnapshot(*tx)
+	if err != nil {
+		return
+	}
+
+	entry, ok := snap[uuid]
+	if !ok || len(entry[key]) == 0 {
+		return
+	}
+	entry[key] = ""
+
+	value, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+
+	tx.Value = string(value)
+	tx.Key = checksumCheckpoint(value)
+}
@@ -0,0 +1,142 @@
+package txlogs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "pass", "one")
+	store.Set(uuid, "user", "bob")
+	store.Set(uuid, "pass", "two")
+	store.Set(uuid, "pass", "three")
+	store.Set(uuid, "old", "gone")
+	store.DeleteKey(uuid, "old")
+	must(t, store.UpdateSnapshot())
+
+	purged, err := store.Purge(uuid, "pass")
+	must(t, err)
+	if purged != 2 {
+		t.Error("should have purged 2 values, got:", purged)
+	}
+	must(t, store.Verify())
+
+	for _, tx := range store.Log {
+		if tx.Value == "one" || tx.Value == "two" {
+			t.Errorf("value was not purged: %#v", tx)
+		}
+		if len(tx.Purged) == 0 {
+			continue
+		}
+
+		// The salt is gone with the value so the digest can't be guessed
+		for _, guess := range []string{"one", "two"} {
+			if tx.Purged == valueDigest(Tx{Value: guess}) || len(tx.Salt) != 0 {
+				t.Errorf("digest can be guessed: %#v", tx)
+			}
+		}
+	}
+	if store.Snapshot[uuid]["pass"] != "three" {
+		t.Error("current value should be kept")
+	}
+
+	// Nothing left to purge for this key
+	purged, err = store.Purge(uuid, "pass")
+	must(t, err)
+	if purged != 0 {
+		t.Error("should not purge again, got:", purged)
+	}
+
+	// Deleted keys have everything purged
+	purged, err = store.Purge(uuid, "")
+	must(t, err)
+	if purged != 1 {
+		t.Error("should have purged the deleted key, got:", purged)
+	}
+	must(t, store.UpdateSnapshot())
+	if store.Snapshot[uuid]["user"] != "bob" {
+		t.Error("current value should be kept")
+	}
+}
+
+func TestPurgeMerge(t *testing.T) {
+	t.Parallel()
+
+	a := new(DB)
+	uuid, err := a.Add()
+	must(t, err)
+	a.Set(uuid, "pass", "secret")
+	a.Set(uuid, "pass", "new")
+
+	b := &DB{Log: make([]Tx, len(a.Log))}
+	copy(b.Log, a.Log)
+	b.Set(uuid, "user", "bob")
+
+	_, err = a.Purge(uuid, "pass")
+	must(t, err)
+
+	// Both directions must end up without the plaintext
+	for _, merged := range [][]Tx{
+		mustMerge(t, a.Log, b.Log),
+		mustMerge(t, b.Log, a.Log),
+	} {
+		for _, tx := range merged {
+			if tx.Value == "secret" {
+				t.Error("purged value came back")
+			}
+		}
+
+		store := &DB{Log: merged}
+		must(t, store.Verify())
+	}
+}
+
+func TestPurgeCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	migrateIDs(store.Log)
+	migrateChain(store.Log)
+	uncompacted := make([]Tx, len(store.Log))
+	copy(uncompacted, store.Log)
+
+	_, err := store.Compact(time.Unix(0, 3))
+	must(t, err)
+
+	purged, err := store.Purge("1", "a")
+	must(t, err)
+	if purged != 1 {
+		t.Error("should have purged the checkpoint, got:", purged)
+	}
+	must(t, store.Verify())
+	if strings.Contains(store.Log[0].Value, `"b"`) {
+		t.Error("checkpoint was not purged:", store.Log[0].Value)
+	}
+
+	// It still lines up with the copy that was never compacted
+	merged := mustMerge(t, uncompacted, store.Log)
+	if merged[0].Kind != TxCheckpoint || merged[0].Value != store.Log[0].Value {
+		t.Errorf("should have used the purged checkpoint: %#v", merged[0])
+	}
+	for _, tx := range merged {
+		if tx.Kind == TxSetKey && tx.Key == "a" && tx.Value == "b" {
+			t.Error("purged value came back")
+		}
+	}
+}
+
+func mustMerge(t *testing.T, a, b []Tx) []Tx {
+	t.Helper()
+
+	merged, conflicts := Merge(a, b, nil)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts should be empty: %#v", conflicts)
+	}
+	return merged
+}
@@ -0,0 +1,85 @@
+package txlogs
+
+import "sort"
+
+// Reconcile appends the transactions needed to make an entry match want and
+// returns how many there were. Keys that are lists in both are reconciled
+// item by item so that it merges cleanly with changes made to the list on
+// other copies.
+//
+// It does not start a transaction of its own, use Do if the changes must be
+// undone together.
+func (s *DB) Reconcile(uuid string, want Entry) (changes int, err error) {
+	if err = s.UpdateSnapshot(); err != nil {
+		return 0, err
+	}
+
+	have, ok := s.Snapshot[uuid]
+	if !ok {
+		return 0, UUIDNotFound(uuid)
+	}
+
+	keys := make([]string, 0, len(want)+len(have))
+	for k := range have {
+		if !IsItemKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range want {
+		if _, ok := have[k]; !ok && !IsItemKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// Work out every change before appending any, have is part of the
+	// snapshot which changes if anything brings it up to date
+	var txs []Tx
+	for _, k := range keys {
+		wantVal, inWant := want[k]
+		haveVal, inHave := have[k]
+
+		switch {
+		case !inWant:
+			txs = append(txs, Tx{Kind: TxDeleteKey, UUID: uuid, Key: k})
+		case len(listItems(want, k)) != 0 && len(listItems(have, k)) != 0:
+			txs = append(txs, reconcileList(uuid, k, have, want)...)
+		case !inHave || haveVal != wantVal:
+			txs = append(txs, Tx{Kind: TxSetKey, UUID: uuid, Key: k, Value: wantVal})
+		}
+	}
+
+	for _, tx := range txs {
+		s.appendLog(tx)
+	}
+
+	return len(txs), nil
+}
+
+// reconcileList removes the items that have values want does not, and adds
+// the values that want has and have does not.
+func reconcileList(uuid, key string, have, want Entry) []Tx {
+	wantValues := make(map[string]struct{})
+	for _, item := range listItems(want, key) {
+		wantValues[item.Value] = struct{}{}
+	}
+
+	var txs []Tx
+	haveValues := make(map[string]struct{})
+	for _, item := range listItems(have, key) {
+		haveValues[item.Value] = struct{}{}
+		if _, ok := wantValues[item.Value]; !ok {
+			txs = append(txs, Tx{Kind: TxDeleteItem, UUID: uuid, Key: key, Index: item.ID})
+		}
+	}
+
+	for _, item := range listItems(want, key) {
+		if _, ok := haveValues[item.Value]; ok {
+			continue
+		}
+		haveValues[item.Value] = struct{}{}
+		txs = append(txs, Tx{Kind: TxAddItem, UUID: uuid, Key: key, Value: item.Value})
+	}
+
+	return txs
+}
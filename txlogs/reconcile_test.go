@@ -0,0 +1,60 @@
+package txlogs
+
+import "testing"
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "1")
+	store.Set(uuid, "b", "2")
+	store.AddItem(uuid, "labels", "x")
+	store.AddItem(uuid, "labels", "y")
+	must(t, store.UpdateSnapshot())
+
+	want := copyEntry(store.Snapshot[uuid])
+
+	store.Set(uuid, "a", "changed")
+	store.DeleteKey(uuid, "b")
+	store.Set(uuid, "c", "new")
+	for _, item := range store.Snapshot[uuid].Items("labels") {
+		if item.Value == "x" {
+			store.DeleteItem(uuid, "labels", item.ID)
+		}
+	}
+	store.AddItem(uuid, "labels", "z")
+	must(t, store.UpdateSnapshot())
+
+	before := len(store.Log)
+	changes, err := store.Reconcile(uuid, want)
+	must(t, err)
+
+	if changes != 5 || len(store.Log)-before != 5 {
+		t.Error("wrong number of changes:", changes)
+	}
+
+	must(t, store.UpdateSnapshot())
+	got := store.Snapshot[uuid]
+	for _, k := range []string{"a", "b", "c"} {
+		if got[k] != want[k] {
+			t.Errorf("%s was wrong, want: %q got: %q", k, want[k], got[k])
+		}
+	}
+	// x was added back so it's at the end now
+	if got["labels"] != "y,x" {
+		t.Error("labels were wrong:", got["labels"])
+	}
+
+	// Nothing left to do
+	changes, err = store.Reconcile(uuid, want)
+	must(t, err)
+	if changes != 0 {
+		t.Error("should have no changes:", changes)
+	}
+
+	if _, err = store.Reconcile("nope", want); !IsUUIDNotFound(err) {
+		t.Error("expected uuid not found:", err)
+	}
+}
@@ -0,0 +1,96 @@
+package txlogs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SafeDB is a DB that can be used by many goroutines at once.
+//
+// The snapshot is brought up to date before every write lets go of the lock
+// so that UpdateSnapshot has nothing to do for readers, and the index and
+// snapshot points are kept up to date by the writes themselves. That makes
+// reading the snapshot, querying the past (NVersions, At, Blame...), Save and
+// the blobformat methods built on them safe to do at the same time.
+type SafeDB struct {
+	mut sync.RWMutex
+	db  *DB
+}
+
+// NewSafeDB wraps db, it must not be used except through the SafeDB after
+// this.
+func NewSafeDB(db *DB) (*SafeDB, error) {
+	if err := db.UpdateSnapshot(); err != nil {
+		return nil, err
+	}
+
+	return &SafeDB{db: db}, nil
+}
+
+// Read calls fn with the read lock held, fn must not change the db. Writes
+// change the entries in the snapshot in place so anything kept after fn
+// returns must be copied.
+func (s *SafeDB) Read(fn func(db *DB) error) error {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return fn(s.db)
+}
+
+// Write calls fn in a transaction with the write lock held, if fn returns an
+// error or its changes can't be applied to the snapshot they're rolled back.
+func (s *SafeDB) Write(fn func(db *DB) error) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.db.Begin()
+	err := fn(s.db)
+	if err == nil {
+		err = s.db.UpdateSnapshot()
+	}
+	if err == nil {
+		s.db.Commit()
+		return nil
+	}
+
+	// The snapshot may have been partly updated before it failed
+	s.db.Rollback()
+	s.db.ResetSnapshot()
+	if snapErr := s.db.UpdateSnapshot(); snapErr != nil {
+		return fmt.Errorf("%w (and failed to rebuild the snapshot: %v)", err, snapErr)
+	}
+	return err
+}
+
+// Merge log into the db, see Merge. If there are conflicts nothing is
+// changed and they're returned to be resolved and passed back in. The db is
+// left as it was if the merged log can't be applied.
+func (s *SafeDB) Merge(log []Tx, resolved []Conflict) ([]Conflict, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	merged, conflicts := Merge(s.db.Log, log, resolved)
+	if len(conflicts) != 0 {
+		return conflicts, nil
+	}
+
+	old := s.db.Log
+	s.db.ReplaceLog(merged)
+	if err := s.db.UpdateSnapshot(); err != nil {
+		s.db.ReplaceLog(old)
+		if snapErr := s.db.UpdateSnapshot(); snapErr != nil {
+			return nil, fmt.Errorf("%w (and failed to rebuild the snapshot: %v)", err, snapErr)
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Save the db, see DB.Save
+func (s *SafeDB) Save() ([]byte, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.db.Save()
+}
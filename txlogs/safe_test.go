@@ -0,0 +1,200 @@
+package txlogs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSafeDB(t *testing.T) {
+	t.Parallel()
+
+	// Small so that writes make snapshot points while the readers use them
+	db := &DB{PointInterval: 5}
+	shared, err := db.Add()
+	must(t, err)
+	db.Set(shared, "name", "shared")
+
+	// Copies of the file that were changed elsewhere and are merged in
+	const nRemotes = 4
+	remotes := make([][]Tx, nRemotes)
+	remoteUUIDs := make([]string, nRemotes)
+	for i := range remotes {
+		remote := &DB{Log: make([]Tx, len(db.Log))}
+		copy(remote.Log, db.Log)
+		remoteUUIDs[i], err = remote.Add()
+		must(t, err)
+		remote.Set(remoteUUIDs[i], "name", fmt.Sprintf("remote%d", i))
+		remotes[i] = remote.Log
+	}
+
+	safe, err := NewSafeDB(db)
+	must(t, err)
+
+	const nWriters, nWrites, nReaders = 4, 50, 4
+	written := make([][]string, nWriters)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, nWriters+nReaders+nRemotes+1)
+
+	for i := 0; i < nWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < nWrites; j++ {
+				err := safe.Write(func(db *DB) error {
+					uuid, err := db.Add()
+					if err != nil {
+						return err
+					}
+					db.Set(uuid, "name", fmt.Sprintf("writer%d-%d", i, j))
+					db.Set(shared, "last", fmt.Sprint(i))
+					written[i] = append(written[i], uuid)
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < nReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < nWrites; j++ {
+				err := safe.Read(func(db *DB) error {
+					if err := db.UpdateSnapshot(); err != nil {
+						return err
+					}
+					if db.Snapshot[shared]["name"] != "shared" {
+						return errors.New("shared entry was wrong")
+					}
+					for _, entry := range db.Snapshot {
+						_ = entry["name"]
+					}
+					if _, err := db.EntrySnapshotAt(shared, 0); err != nil {
+						return err
+					}
+					_, err := db.AtVersion(len(db.Log) - 1)
+					return err
+				})
+				if err == nil {
+					_, err = safe.Save()
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for i := range remotes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conflicts, err := safe.Merge(remotes[i], nil)
+			if err == nil && len(conflicts) != 0 {
+				err = fmt.Errorf("conflicts should be empty: %#v", conflicts)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < nWrites; j++ {
+			err := safe.Read(func(db *DB) error {
+				if db.NVersions(shared) == 0 {
+					return errors.New("shared entry had no versions")
+				}
+				_, err := db.AtVersion(len(db.Log) / 2)
+				return err
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	must(t, safe.Read(func(db *DB) error {
+		if want := 2 + nWriters*nWrites*3 + nRemotes*2; len(db.Log) != want {
+			t.Errorf("log should have %d transactions: %d", want, len(db.Log))
+		}
+		if int(db.Version) != len(db.Log) {
+			t.Error("snapshot should be up to date:", db.Version)
+		}
+		for i, uuids := range written {
+			for j, uuid := range uuids {
+				if got := db.Snapshot[uuid]["name"]; got != fmt.Sprintf("writer%d-%d", i, j) {
+					t.Errorf("writer %d write %d was wrong: %q", i, j, got)
+				}
+			}
+		}
+		for i, uuid := range remoteUUIDs {
+			if got := db.Snapshot[uuid]["name"]; got != fmt.Sprintf("remote%d", i) {
+				t.Errorf("remote %d was wrong: %q", i, got)
+			}
+		}
+		if err := db.Verify(); err != nil {
+			t.Error(err)
+		}
+		return nil
+	}))
+}
+
+func TestSafeDBWriteRollback(t *testing.T) {
+	t.Parallel()
+
+	db := new(DB)
+	uuid, err := db.Add()
+	must(t, err)
+	db.Set(uuid, "name", "value")
+
+	safe, err := NewSafeDB(db)
+	must(t, err)
+
+	want := errors.New("failed")
+	err = safe.Write(func(db *DB) error {
+		db.Set(uuid, "name", "changed")
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Error("error was wrong:", err)
+	}
+
+	// Deleting a key from an entry that doesn't exist can't be applied to
+	// the snapshot
+	err = safe.Write(func(db *DB) error {
+		db.Set(uuid, "name", "changed")
+		db.DeleteKey("nonexistent", "name")
+		return nil
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	must(t, safe.Read(func(db *DB) error {
+		if len(db.Log) != 2 {
+			t.Error("log should be rolled back:", len(db.Log))
+		}
+		if got := db.Snapshot[uuid]["name"]; got != "value" {
+			t.Error("snapshot should be rolled back:", got)
+		}
+		return nil
+	}))
+}
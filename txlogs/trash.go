@@ -0,0 +1,109 @@
+package txlogs
+
+import (
+	"sort"
+	"time"
+
+	uuidpkg "github.com/gofrs/uuid"
+)
+
+// Deleted is an entry that was deleted, Entry is what it had when it was
+type Deleted struct {
+	UUID  string
+	Time  time.Time
+	Entry Entry
+}
+
+// Deleted returns the entries that were deleted in the order they were
+// deleted. Entries that were deleted before the log was compacted are gone
+// and are not returned, nor are entries that are back (a merge that kept
+// changes made to them after they were deleted for example) or that were
+// restored with Undelete.
+func (s *DB) Deleted() ([]Deleted, error) {
+	var deleted []Deleted
+	restored := make(map[string]struct{})
+	snap := make(map[string]Entry)
+	for _, tx := range s.Log {
+		if tx.Kind == TxAdd && len(tx.Value) != 0 {
+			restored[tx.Value] = struct{}{}
+		}
+		if tx.Kind == TxDelete {
+			if entry, ok := snap[tx.UUID]; ok {
+				deleted = append(deleted, Deleted{
+					UUID:  tx.UUID,
+					Time:  time.Unix(0, tx.Time),
+					Entry: copyEntry(entry),
+				})
+			}
+		}
+
+		if err := applyTx(snap, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	live := deleted[:0]
+	for _, d := range deleted {
+		_, isLive := snap[d.UUID]
+		_, isRestored := restored[d.UUID]
+		if !isLive && !isRestored {
+			live = append(live, d)
+		}
+	}
+
+	return live, nil
+}
+
+// Undelete re-creates a deleted entry with the keys it had when it was
+// deleted and returns its new uuid. It's given a new uuid because any change
+// to the old one after its deletion is a delete/set conflict when merged. The
+// add of the new uuid has the old one as its value so that it's no longer
+// listed by Deleted, on this copy or any it's merged with.
+//
+// It does not start a transaction of its own, use Do if the changes must be
+// undone together.
+func (s *DB) Undelete(uuid string) (newUUID string, err error) {
+	deleted, err := s.Deleted()
+	if err != nil {
+		return "", err
+	}
+
+	var entry Entry
+	for _, d := range deleted {
+		if d.UUID == uuid {
+			entry = d.Entry
+		}
+	}
+	if entry == nil {
+		return "", UUIDNotFound(uuid)
+	}
+
+	keys := make([]string, 0, len(entry))
+	for k := range entry {
+		if !IsItemKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	uuidObj, err := uuidpkg.NewV4()
+	if err != nil {
+		return "", err
+	}
+	newUUID = uuidObj.String()
+	s.appendLog(Tx{Kind: TxAdd, UUID: newUUID, Value: uuid})
+
+	for _, k := range keys {
+		items := listItems(entry, k)
+		if len(items) == 0 {
+			s.Set(newUUID, k, entry[k])
+			continue
+		}
+
+		for _, item := range items {
+			s.AddItem(newUUID, k, item.Value)
+		}
+	}
+
+	return newUUID, nil
+}
@@ -0,0 +1,88 @@
+package txlogs
+
+import "testing"
+
+func TestUndelete(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	other, err := store.Add()
+	must(t, err)
+
+	store.Set(uuid, "a", "b")
+	store.AddItem(uuid, "labels", "x")
+	store.AddItem(uuid, "labels", "y")
+	store.Delete(uuid)
+
+	deleted, err := store.Deleted()
+	must(t, err)
+	if len(deleted) != 1 || deleted[0].UUID != uuid || deleted[0].Entry["labels"] != "x,y" {
+		t.Fatalf("deleted was wrong: %#v", deleted)
+	}
+
+	// Another copy changes something else at the same time
+	remote := &DB{Log: append([]Tx(nil), store.Log...)}
+	remote.Set(other, "c", "d")
+
+	newUUID, err := store.Undelete(uuid)
+	must(t, err)
+	if newUUID == uuid {
+		t.Error("it should have a new uuid")
+	}
+
+	store.Log = mustMerge(t, store.Log, remote.Log)
+	must(t, store.UpdateSnapshot())
+
+	// It's been restored so it's not in the trash anymore, on either copy
+	for _, db := range []*DB{store, {Log: mustMerge(t, remote.Log, store.Log)}} {
+		deleted, err = db.Deleted()
+		must(t, err)
+		if len(deleted) != 0 {
+			t.Errorf("trash should be empty: %#v", deleted)
+		}
+	}
+	if _, err = store.Undelete(uuid); !IsUUIDNotFound(err) {
+		t.Error("it can't be restored twice:", err)
+	}
+
+	entry := store.Snapshot[newUUID]
+	if entry["a"] != "b" || entry["labels"] != "x,y" || len(entry.Items("labels")) != 2 {
+		t.Errorf("entry was wrong: %#v", entry)
+	}
+	if store.Snapshot[other]["c"] != "d" {
+		t.Error("remote change was lost")
+	}
+
+	if _, err = store.Undelete(other); !IsUUIDNotFound(err) {
+		t.Error("expected uuid not found:", err)
+	}
+}
+
+func TestDeletedLive(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "a", "b")
+	store.Delete(uuid)
+
+	// Brought back with the same uuid, the way a merge that keeps changes
+	// made after the delete does
+	store.appendLog(Tx{Kind: TxAdd, UUID: uuid})
+	must(t, store.UpdateSnapshot())
+	if _, ok := store.Snapshot[uuid]; !ok {
+		t.Fatal("entry should be live")
+	}
+
+	deleted, err := store.Deleted()
+	must(t, err)
+	if len(deleted) != 0 {
+		t.Errorf("live entries should not be listed: %#v", deleted)
+	}
+	if _, err = store.Undelete(uuid); !IsUUIDNotFound(err) {
+		t.Error("expected uuid not found:", err)
+	}
+}
@@ -12,21 +12,61 @@ const (
 	// Set and Delete key correspond to key's on entries
 	TxSetKey    TxKind = "setk"
 	TxDeleteKey TxKind = "delk"
+
+	// Add and Delete item correspond to items in a list on an entry
+	TxAddItem    TxKind = "addi"
+	TxDeleteItem TxKind = "deli"
+
+	// Checkpoint replaces all history before it with the snapshot of the
+	// data at that point, see DB.Compact
+	TxCheckpoint TxKind = "ckpt"
+
+	// Purge redacts old values from the log, see DB.Purge
+	TxPurge TxKind = "purge"
+
+	// Repair removes transactions that can't be applied, see DB.Repair
+	TxRepair TxKind = "repair"
 )
 
 // Tx is a transaction that changes an Entry in some way
 type Tx struct {
 	// These fields are metadata about the change
+	// ID = Unique hybrid logical clock timestamp, the tx's identity
+	// Time = Wall clock time in unix nanoseconds (for humans)
+	// Prev = Hash of the transaction before this one (see DB.Verify)
+	// User = Who made the change, empty unless the file is multi-user
+	ID   string `msgpack:"id,omitempty" json:"id,omitempty"`
 	Time int64  `msgpack:"time,omitempty" json:"time,omitempty"`
 	Kind TxKind `msgpack:"kind,omitempty" json:"kind,omitempty"`
+	Prev string `msgpack:"prev,omitempty" json:"prev,omitempty"`
+	User string `msgpack:"user,omitempty" json:"user,omitempty"`
 
 	// The fields below relate to the object being changed
 	// UUID = The object's id
 	// Key = The name of the property being changed
 	// Value = The value to change to
-	UUID  string `msgpack:"uuid,omitempty" json:"uuid,omitempty"`
-	Key   string `msgpack:"key,omitempty" json:"key,omitempty"`
-	Value string `msgpack:"value,omitempty" json:"value,omitempty"`
+	// Index = The id of the list item being removed
+	// Purged = Digest of the Value before it was redacted
+	// Salt = Random value mixed into the digest of a set's Value, it's
+	//        redacted with the Value so the digest can't be guessed
+	//
+	// Checkpoints have no UUID, the Key is the checksum (sha256) of the
+	// Value which is the json encoded snapshot.
+	//
+	// Purges have the UUID and Key that were redacted, the Value is a comma
+	// separated list of the ids of the transactions that were redacted.
+	//
+	// Adds made by Undelete have the UUID of the entry they restore as the
+	// Value.
+	//
+	// Repairs have no UUID, the Value is a comma separated list of the ids
+	// of the transactions that were removed.
+	UUID   string `msgpack:"uuid,omitempty" json:"uuid,omitempty"`
+	Key    string `msgpack:"key,omitempty" json:"key,omitempty"`
+	Value  string `msgpack:"value,omitempty" json:"value,omitempty"`
+	Index  string `msgpack:"index,omitempty" json:"index,omitempty"`
+	Purged string `msgpack:"purged,omitempty" json:"purged,omitempty"`
+	Salt   string `msgpack:"salt,omitempty" json:"salt,omitempty"`
 }
 
 // conflict types
@@ -37,6 +77,15 @@ const (
 	// ConflictKindRoot occurs when there is no shared history between
 	// the two histories.
 	ConflictKindRoot
+	// ConflictKindSetSet occurs when the same key on an entry was set to
+	// different values on both sides of a fork. Initial is the last set on
+	// the local side and Conflict is the last set on the remote side.
+	ConflictKindSetSet
+	// ConflictKindBrokenChain occurs when the hash chain of one of the logs
+	// is broken (see Verify), the broken transaction is in Initial if it's
+	// the local side and in Conflict if it's the remote side. It can't be
+	// resolved, merging it would link the tampered history again.
+	ConflictKindBrokenChain
 )
 
 // conflict resolutions
@@ -48,7 +97,8 @@ const (
 )
 
 // Conflict occurs when a set occurs after a delete (meaning one sync'd copy
-// added data to one that was deleted in the past)
+// added data to one that was deleted in the past), when two copies set the
+// same key to different values, or when there is no shared history.
 type Conflict struct {
 	Kind int
 
@@ -59,12 +109,14 @@ type Conflict struct {
 }
 
 // DiscardConflict discards the transaction that conflicts with initial.
+// For ConflictKindSetSet this keeps the value from Initial.
 func (c *Conflict) DiscardConflict() {
 	c.resolution = resolveDiscardConflict
 }
 
 // DiscardInitial discards the initial that created the state where the
-// conflict could occur.
+// conflict could occur. For ConflictKindSetSet this keeps the value from
+// Conflict.
 func (c *Conflict) DiscardInitial() {
 	c.resolution = resolveDiscardInitial
 }
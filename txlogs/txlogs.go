@@ -2,9 +2,9 @@
 package txlogs
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	uuidpkg "github.com/gofrs/uuid"
@@ -38,8 +38,30 @@ type DB struct {
 	Snapshot map[string]Entry `msgpack:"snapshot,omitempty" json:"snapshot,omitempty"`
 	// Log of all transactions.
 	Log []Tx `msgpack:"log,omitempty" json:"log,omitempty"`
+	// Points are snapshots made every PointInterval transactions as the log
+	// is changed, they're only saved if PersistPoints is set.
+	Points []SnapshotPoint `msgpack:"points,omitempty" json:"points,omitempty"`
+
+	PointInterval int  `msgpack:"-" json:"-"`
+	PersistPoints bool `msgpack:"-" json:"-"`
+	// User is recorded as the author of each transaction appended
+	User string `msgpack:"-" json:"-"`
+	// Codec is what Save encodes with, New sets it to what it decoded
+	Codec Codec `msgpack:"-" json:"-"`
+
+	savepoints []savepoint
+	clock      clock
+	index      index
+
+	subscribers    []subscriber
+	lastSubscriber int
+}
 
-	txPoint int
+// savepoint is where Begin was called, the length of the log and the id of
+// the last transaction at the time.
+type savepoint struct {
+	point int
+	head  string
 }
 
 // Entry is a cached entry in the store, it holds the values as currently
@@ -50,33 +72,62 @@ type storeNoSnapshot struct {
 	Log []Tx `msgpack:"log,omitempty" json:"log,omitempty"`
 }
 
-// New takes a json blob and unmarshals it into a DB
+// New takes a json or msgpack blob and unmarshals it into a DB, the codec is
+// detected with DetectCodec. Use NewCodec when the codec is known.
 func New(data []byte) (*DB, error) {
+	return NewCodec(data, DetectCodec(data))
+}
+
+// NewCodec unmarshals a blob encoded with codec into a DB. Transactions from
+// before ids existed are given one, and logs from before hash chains existed
+// are chained.
+func NewCodec(data []byte, codec Codec) (*DB, error) {
 	s := new(DB)
-	if err := json.Unmarshal(data, &s); err != nil {
+	if err := codec.unmarshal(data, &s); err != nil {
 		return nil, err
 	}
+	if s == nil {
+		s = new(DB)
+	}
+	s.Codec = codec
 
+	migrateIDs(s.Log)
+	migrateChain(s.Log)
+	s.updateIndex()
+	s.updatePoints()
 	return s, nil
 }
 
 // NewLog parses the same data as New() but only returns the log
 func NewLog(data []byte) ([]Tx, error) {
+	return NewLogCodec(data, DetectCodec(data))
+}
+
+// NewLogCodec parses the same data as NewCodec() but only returns the log
+func NewLogCodec(data []byte, codec Codec) ([]Tx, error) {
 	s := new(storeNoSnapshot)
-	if err := json.Unmarshal(data, &s); err != nil {
+	if err := codec.unmarshal(data, &s); err != nil {
 		return nil, err
 	}
 
+	migrateIDs(s.Log)
+	migrateChain(s.Log)
 	return s.Log, nil
 }
 
-// Save marshals as json blob
+// Save marshals with the DB's codec
 func (s *DB) Save() ([]byte, error) {
-	if s.txPoint != 0 {
+	if s.InTransaction() {
 		return nil, errors.New("refusing to save while transaction active")
 	}
 
-	return json.Marshal(s)
+	// A copy is marshalled so that saving never changes the DB
+	save := *s
+	if !s.PersistPoints {
+		save.Points = nil
+	}
+
+	return s.Codec.marshal(&save)
 }
 
 // Add a new entry
@@ -86,14 +137,22 @@ func (s *DB) Add() (uuid string, err error) {
 		return "", err
 	}
 
-	// Does not use appendLog so ID/Time must be filled out by hand
+	// Does not use appendLog so ID/Time/Prev must be filled out by hand
 	s.Log = append(s.Log,
 		Tx{
+			ID:   s.nextID(),
 			Time: time.Now().UnixNano(),
 			Kind: TxAdd,
+			Prev: head(s.Log),
+			User: s.User,
 			UUID: uuidObj.String(),
 		},
 	)
+	s.indexAppended()
+	s.pointsAppended()
+	if len(s.subscribers) != 0 {
+		s.notify(Change{Appended: copyTxs(s.Log[len(s.Log)-1:])})
+	}
 
 	return uuidObj.String(), nil
 }
@@ -131,41 +190,87 @@ func (s *DB) DeleteKey(uuid, key string) {
 	)
 }
 
-// appendLog creates a new UUID for tx.ID and appends the log
+// appendLog creates a new ID for tx.ID, links it to the chain and appends
+// the log
 func (s *DB) appendLog(tx Tx) {
+	if tx.Kind == TxSetKey && len(tx.Salt) == 0 {
+		tx.Salt = newSalt()
+	}
+	tx.ID = s.nextID()
 	tx.Time = time.Now().UnixNano()
+	tx.Prev = head(s.Log)
+	tx.User = s.User
 	s.Log = append(s.Log, tx)
+	s.indexAppended()
+	s.pointsAppended()
+	if len(s.subscribers) != 0 {
+		s.notify(Change{Appended: []Tx{tx}})
+	}
+}
+
+// nextID returns an id that sorts after every transaction in the log
+func (s *DB) nextID() string {
+	if n := len(s.Log); n != 0 {
+		s.clock.observe(s.Log[n-1].ID)
+	}
+	return s.clock.next()
 }
 
-// Begin a transaction, will panic if commit/rollback have not been issued
-// after a previous Begin.
+// Begin a transaction. Calling Begin again before Commit or Rollback starts
+// a savepoint inside the transaction, each is ended by the next Commit or
+// Rollback and the transaction ends with the outermost one.
 //
-// We add 1 to the length to keep the 0 valid as a "no transaction started"
-// sentinel value.
+// The id of the last transaction is kept as well as the length of the log so
+// that Rollback can find its place even if the log was changed underneath it.
 func (s *DB) Begin() {
-	s.txPoint = len(s.Log) + 1
+	sp := savepoint{point: len(s.Log)}
+	if len(s.Log) != 0 {
+		sp.head = s.Log[len(s.Log)-1].ID
+	}
+	s.savepoints = append(s.savepoints, sp)
 }
 
-// Commit the transactions to the log
+// Commit the transactions since the last Begin, if it was a savepoint they
+// are still undone by a Rollback of the transaction around it.
 func (s *DB) Commit() {
-	if s.txPoint == 0 {
+	if len(s.savepoints) == 0 {
 		panic("commit called before begin")
 	}
-	s.txPoint = 0
+	s.savepoints = s.savepoints[:len(s.savepoints)-1]
 }
 
 // Rollback to the last begin point, invalidates the snapshot if necessary
 func (s *DB) Rollback() {
-	if s.txPoint == 0 {
+	if len(s.savepoints) == 0 {
 		panic("rollback called before begin")
 	}
 
-	if s.Version > uint(s.txPoint) {
+	sp := s.savepoints[len(s.savepoints)-1]
+	s.savepoints = s.savepoints[:len(s.savepoints)-1]
+
+	point := sp.point
+	if len(sp.head) != 0 {
+		for i := len(s.Log) - 1; i >= 0; i-- {
+			if s.Log[i].ID == sp.head {
+				point = i + 1
+				break
+			}
+		}
+	}
+	if point > len(s.Log) {
+		point = len(s.Log)
+	}
+
+	if s.Version > uint(point) {
 		s.ResetSnapshot()
 	}
 
-	s.Log = s.Log[:s.txPoint-1]
-	s.txPoint = 0
+	s.truncate(point)
+}
+
+// InTransaction is true between a Begin and its Commit or Rollback
+func (s *DB) InTransaction() bool {
+	return len(s.savepoints) != 0
 }
 
 // Do a transaction, if an error is returned by the lambda then
@@ -197,7 +302,7 @@ func (s *DB) RollbackN(n uint) error {
 		s.ResetSnapshot()
 	}
 
-	s.Log = s.Log[:ln-n]
+	s.truncate(int(ln - n))
 
 	return nil
 }
@@ -228,6 +333,23 @@ func (s *DB) UpdateSnapshot() error {
 	return nil
 }
 
+// Walk replays the log calling fn after each transaction is applied with the
+// snapshot as it was then. The snapshot is changed by the next transaction
+// so fn must copy anything it keeps.
+func (s *DB) Walk(fn func(i int, tx Tx, snap map[string]Entry) error) error {
+	snap := make(map[string]Entry)
+	for i, tx := range s.Log {
+		if err := applyTx(snap, tx); err != nil {
+			return err
+		}
+		if err := fn(i, tx, snap); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // SnapshotAt creates a new snapshot of a particular entry versionsAgo
 // in the past.
 func (s *DB) SnapshotAt(versionsAgo int) (map[string]Entry, error) {
@@ -235,15 +357,7 @@ func (s *DB) SnapshotAt(versionsAgo int) (map[string]Entry, error) {
 		return nil, errors.New("there are not that many versions")
 	}
 
-	stopVersion := len(s.Log) - 1 - versionsAgo
-	snap := make(map[string]Entry)
-	for i := 0; i <= stopVersion; i++ {
-		if err := applyTx(snap, s.Log[i]); err != nil {
-			return nil, err
-		}
-	}
-
-	return snap, nil
+	return s.replay(len(s.Log) - versionsAgo)
 }
 
 // EntrySnapshotAt creates a new snapshot of a particular entry versionsAgo
@@ -254,14 +368,7 @@ func (s *DB) EntrySnapshotAt(uuid string, versionsAgo int) (Entry, error) {
 		return nil, errors.New("there are not that many versions")
 	}
 
-	entryTxIndicies := make([]int, 0)
-	for i := 0; i < len(s.Log); i++ {
-		if s.Log[i].UUID != uuid {
-			continue
-		}
-
-		entryTxIndicies = append(entryTxIndicies, i)
-	}
+	entryTxIndicies := s.entryTxs(uuid)
 
 	if versionsAgo > len(entryTxIndicies) {
 		return nil, errors.New("there are not that many versions for the entry")
@@ -269,7 +376,22 @@ func (s *DB) EntrySnapshotAt(uuid string, versionsAgo int) (Entry, error) {
 
 	stopVersion := len(entryTxIndicies) - 1 - versionsAgo
 	snap := make(map[string]Entry, 1)
-	for i := 0; i <= stopVersion; i++ {
+
+	// Start from the entry as it was at the nearest point
+	i := 0
+	if stopVersion >= 0 {
+		if p := s.nearestPoint(entryTxIndicies[stopVersion] + 1); p >= 0 {
+			point := s.Points[p]
+			if entry, ok := point.Snapshot[uuid]; ok {
+				snap[uuid] = copyEntry(entry)
+			}
+			for i < len(entryTxIndicies) && entryTxIndicies[i] < point.Version {
+				i++
+			}
+		}
+	}
+
+	for ; i <= stopVersion; i++ {
 		index := entryTxIndicies[i]
 		if err := applyTx(snap, s.Log[index]); err != nil {
 			return nil, err
@@ -285,28 +407,18 @@ func (s *DB) EntrySnapshotAt(uuid string, versionsAgo int) (Entry, error) {
 
 // NVersions returns the number of versions we have recorded about an item
 func (s *DB) NVersions(uuid string) (versions int) {
-	for _, l := range s.Log {
-		if l.UUID == uuid {
-			versions++
-		}
-	}
-
-	return versions
+	return len(s.entryTxs(uuid))
 }
 
 // LastUpdated returns the unix nanosecond timestamp for when the entry was
 // updated last. Will be -1 if the entry is not found.
 func (s *DB) LastUpdated(uuid string) (last int64) {
-	last = -1
-
-	for i := len(s.Log) - 1; i >= 0; i-- {
-		if s.Log[i].UUID == uuid {
-			last = s.Log[i].Time
-			break
-		}
+	txs := s.entryTxs(uuid)
+	if len(txs) == 0 {
+		return -1
 	}
 
-	return last
+	return s.Log[txs[len(txs)-1]].Time
 }
 
 // Merge logs together. The standard case for merging is that the logs proceed
@@ -316,28 +428,64 @@ func (s *DB) LastUpdated(uuid string) (last int64) {
 // and they start and end with the same transaction ids.
 //
 // When a fork occurs the logs need to be reconciled. The reconciliation is
-// done by accepting each change in order deterministically, it sorts first
-// by timestamp, then by uuid as a fallback in case the changes (unlikely)
-// happened inside the timestamps max resolution.
+// done by accepting each change in order deterministically, it sorts by
+// transaction id which is a hybrid logical clock so it's unique even if
+// two changes happened at the same time on different machines or a clock
+// went backwards.
 //
-// The only conflicting situation is where an event occurs on an item after
-// it has been deleted. In this case the conflicts are returned and must
-// be resolved and passed back into this method for it to complete.
+// There are two conflicting situations. The first is where an event occurs
+// on an item after it has been deleted. The second is where the same key on
+// an item was set to different values on both sides of the fork. In these
+// cases the conflicts are returned and must be resolved and passed back into
+// this method for it to complete.
 //
 // If conflicts have not been resolved the same set of conflicts will simply
 // be returned.
+//
+// If both logs are hash chained the fork is found by comparing the hashes
+// instead of walking the shared history, and the transactions after the
+// fork are linked again in their new order.
+//
+// If either log has been compacted the other is folded at the same
+// checkpoint before merging so they share ancestry again. If that's not
+// possible (the histories before the checkpoint differ) it's a
+// ConflictKindRoot.
+//
+// Both logs are verified first since the transactions after the fork are
+// linked again, if either is broken a ConflictKindBrokenChain is returned.
 func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
+	if broken, ok := brokenChain(a); ok {
+		return nil, []Conflict{{Kind: ConflictKindBrokenChain, Initial: broken}}
+	}
+	if broken, ok := brokenChain(b); ok {
+		return nil, []Conflict{{Kind: ConflictKindBrokenChain, Conflict: broken}}
+	}
+
 	for _, r := range resolved {
 		if r.resolution == resolveNone {
 			return nil, resolved
 		}
 	}
 
+	// Repairs from either side must apply to the other's copies, they're
+	// removed before merging so they can't conflict
+	repaired := repairTargets(a, b)
+	a, _ = unrepaired(a, repaired)
+	b, _ = unrepaired(b, repaired)
+
+	a, b = alignCheckpoints(a, b)
+
 	lena := len(a)
 	lenb := len(b)
+	linked := chained(a) && chained(b)
 
-	if lena == lenb &&
-		a[0].Time == b[0].Time && a[lena-1].Time == b[lenb-1].Time {
+	if linked {
+		// The head of the chain covers everything before it
+		if lena == lenb && head(a) == head(b) {
+			return a, nil
+		}
+	} else if lena == lenb &&
+		txID(a[0]) == txID(b[0]) && txID(a[lena-1]) == txID(b[lenb-1]) {
 		// These are the same list of events
 		// There can be no possible fork that has happened if they
 		// 1. Are not of differing length
@@ -375,7 +523,7 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 			// Before we mark ourselves as deleted, make sure we aren't
 			// part of a resolution.
 			for _, res := range resolved {
-				if res.Initial.Time != c[last].Time {
+				if txID(res.Initial) != txID(c[last]) {
 					continue
 				}
 
@@ -396,7 +544,7 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 		deleteTx := c[ind]
 		// Check if its resolved
 		for _, res := range resolved {
-			if res.Initial.Time == deleteTx.Time {
+			if txID(res.Initial) == txID(deleteTx) {
 				// Assert for the impossible, and delete ourselves off the end
 				// This is impossible because if it was resolved in the other
 				// way it should have been handled above.
@@ -410,7 +558,7 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 
 		// Make sure we haven't noted this one already first
 		for _, con := range conflicts {
-			if con.Initial.Time == deleteTx.Time {
+			if txID(con.Initial) == txID(deleteTx) {
 				return
 			}
 		}
@@ -424,13 +572,27 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 	}
 
 	i, j := 0, 0
+	forked, forkA, forkB := false, 0, 0
+	if linked {
+		// Skip straight to the fork, only deletes need to be remembered
+		// from the shared history
+		i = forkPoint(a, b)
+		j = i
+		for k := 0; k < i; k++ {
+			if a[k].Kind == TxDelete {
+				deleted[a[k].UUID] = k
+			}
+		}
+		c = append(c, a[:i]...)
+	}
+
 	for {
 		if i >= lena || j >= lenb {
 			break
 		}
 
 		// If ids are the same, append and move on, haven't reached fork
-		if a[i].Time == b[j].Time {
+		if txID(a[i]) == txID(b[j]) {
 			if a[i].Kind == TxDelete {
 				deleted[a[i].UUID] = i
 			}
@@ -442,6 +604,11 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 		}
 
 		// We've forked.
+		if !forked {
+			forked = true
+			forkA, forkB = i, j
+		}
+
 		// If the fork happens and we have not moved either i or j
 		// that means that there is no common ancestry and this is likely a
 		// mistake to be syncing these. Create a conflict. This will always
@@ -458,7 +625,7 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 		}
 
 		// Compare the txs
-		if a[i].Time < b[j].Time {
+		if txID(a[i]) < txID(b[j]) {
 			c = append(c, a[i])
 			i++
 		} else {
@@ -479,13 +646,170 @@ func Merge(a, b []Tx, resolved []Conflict) (c []Tx, conflicts []Conflict) {
 		checkConflict()
 	}
 
+	if forked {
+		c, conflicts = setConflicts(c, a[forkA:], b[forkB:], resolved, conflicts)
+	}
+
 	if len(conflicts) != 0 {
 		return nil, conflicts
 	}
 
+	// Purges from either side must apply to the other's copies
+	redact(c)
+
+	// Everything after the fork has new neighbours
+	if chained(a) || chained(b) {
+		start := forkPoint(a, c)
+		if len(repaired) != 0 {
+			// Removing transactions leaves links that are stale even if
+			// both sides agree on what comes after
+			for i := 1; i < start; i++ {
+				if c[i].Prev != hashTx(c[i-1]) {
+					start = i
+					break
+				}
+			}
+		}
+		if !linked {
+			start = 1
+		}
+		chain(c, start)
+	}
+
 	return c, nil
 }
 
+// LatestKeys are keys that hold a unix nanosecond timestamp that's kept up
+// to date as other keys change, like when an entry was last updated. Both
+// sides of a fork setting one is not a conflict, the later time is kept.
+var LatestKeys = make(map[string]struct{})
+
+// setConflicts finds keys that were set to different values on both sides
+// of a fork. a and b are the transactions after the fork on each side, ones
+// that both sides have (from a previous merge) are ignored.
+//
+// Resolutions are applied by setting the key to the kept value after
+// everything else in c. Removing the discarded sets instead would only see
+// them come back the next time a copy that still has them is merged.
+func setConflicts(c, a, b []Tx, resolved, conflicts []Conflict) ([]Tx, []Conflict) {
+	lastA := lastSets(a, b)
+	lastB := lastSets(b, a)
+
+	for _, tx := range a {
+		key := setKey(tx)
+		initial, ok := lastA[key]
+		if !ok || txID(initial) != txID(tx) {
+			continue
+		}
+		conflict, ok := lastB[key]
+		if !ok || conflict.Value == initial.Value {
+			continue
+		}
+		if _, ok := LatestKeys[tx.Key]; ok {
+			c = keepSet(c, latestSet(initial, conflict))
+			continue
+		}
+
+		resolution := resolveNone
+		for _, res := range resolved {
+			if res.Kind == ConflictKindSetSet &&
+				txID(res.Initial) == txID(initial) &&
+				txID(res.Conflict) == txID(conflict) {
+				resolution = res.resolution
+				break
+			}
+		}
+
+		switch resolution {
+		case resolveDiscardInitial:
+			c = keepSet(c, conflict)
+		case resolveDiscardConflict:
+			c = keepSet(c, initial)
+		default:
+			conflicts = append(conflicts, Conflict{
+				Kind:     ConflictKindSetSet,
+				Initial:  initial,
+				Conflict: conflict,
+			})
+		}
+	}
+
+	return c, conflicts
+}
+
+// lastSets finds the last set of each uuid/key in log that is not in other
+func lastSets(log, other []Tx) map[string]Tx {
+	shared := make(map[string]struct{}, len(other))
+	for _, tx := range other {
+		shared[txID(tx)] = struct{}{}
+	}
+
+	sets := make(map[string]Tx)
+	for _, tx := range log {
+		if tx.Kind != TxSetKey {
+			continue
+		}
+		if _, ok := shared[txID(tx)]; ok {
+			continue
+		}
+		sets[setKey(tx)] = tx
+	}
+
+	return sets
+}
+
+// latestSet returns the set with the later time of two sets of a key in
+// LatestKeys, values that aren't times sort before ones that are
+func latestSet(a, b Tx) Tx {
+	atime, aerr := strconv.ParseInt(a.Value, 10, 64)
+	btime, berr := strconv.ParseInt(b.Value, 10, 64)
+	switch {
+	case aerr != nil && berr != nil:
+		if a.Value > b.Value {
+			return a
+		}
+		return b
+	case berr != nil:
+		return a
+	case aerr != nil:
+		return b
+	case atime > btime:
+		return a
+	default:
+		return b
+	}
+}
+
+func setKey(tx Tx) string {
+	return tx.UUID + "\x00" + tx.Key
+}
+
+// keepSet makes sure the value of the set is what the key ends up as in log
+func keepSet(log []Tx, set Tx) []Tx {
+	for i := len(log) - 1; i >= 0; i-- {
+		tx := log[i]
+		if tx.UUID != set.UUID {
+			continue
+		}
+		if tx.Kind == TxDelete {
+			// The entry is gone, there's nothing to keep
+			return log
+		}
+		if (tx.Kind == TxSetKey || tx.Kind == TxDeleteKey) && tx.Key == set.Key {
+			if tx.Kind == TxSetKey && tx.Value == set.Value {
+				return log
+			}
+			break
+		}
+	}
+
+	// The id is derived from the log so that resolving the same conflict
+	// the same way on another copy creates the same transaction
+	set.ID = idAfter(txID(log[len(log)-1]), txID(set))
+	set.Prev = ""
+	return append(log, set)
+}
+
 // applyTx applies the src transactions to the destination snapshot
 func applyTx(dst map[string]Entry, tx Tx) error {
 	switch tx.Kind {
@@ -507,6 +831,7 @@ func applyTx(dst map[string]Entry, tx Tx) error {
 			return err
 		}
 
+		clearList(entry, tx.Key)
 		entry[tx.Key] = tx.Value
 	case TxDeleteKey:
 		entry, err := getEntry(dst, tx.UUID)
@@ -514,7 +839,39 @@ func applyTx(dst map[string]Entry, tx Tx) error {
 			return err
 		}
 
+		clearList(entry, tx.Key)
 		delete(entry, tx.Key)
+	case TxAddItem:
+		entry, err := getEntry(dst, tx.UUID)
+		if err != nil {
+			return err
+		}
+
+		convertList(entry, tx.Key)
+		entry[itemKey(tx.Key, txID(tx))] = tx.Value
+		joinList(entry, tx.Key)
+	case TxDeleteItem:
+		entry, err := getEntry(dst, tx.UUID)
+		if err != nil {
+			return err
+		}
+
+		// Not finding the item is fine, both copies may have removed it
+		convertList(entry, tx.Key)
+		delete(entry, itemKey(tx.Key, tx.Index))
+		joinList(entry, tx.Key)
+	case TxCheckpoint:
+		snap, err := checkpointSnapshot(tx)
+		if err != nil {
+			return err
+		}
+
+		for uuid, entry := range snap {
+			if _, ok := dst[uuid]; ok {
+				return fmt.Errorf("%s already exists in snapshot", uuid)
+			}
+			dst[uuid] = entry
+		}
 	}
 
 	return nil
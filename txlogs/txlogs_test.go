@@ -188,6 +188,70 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestMarshalCodecs(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "test1", "value")
+	store.AddItem(uuid, "labels", "label")
+	must(t, store.UpdateSnapshot())
+
+	jsonData, err := store.Save()
+	must(t, err)
+
+	store.Codec = CodecMsgpack
+	msgpackData, err := store.Save()
+	must(t, err)
+
+	if len(msgpackData) >= len(jsonData) {
+		t.Errorf("msgpack should be smaller: %d >= %d", len(msgpackData), len(jsonData))
+	}
+
+	for _, test := range []struct {
+		Codec Codec
+		Data  []byte
+	}{{CodecJSON, jsonData}, {CodecMsgpack, msgpackData}} {
+		if got := DetectCodec(test.Data); got != test.Codec {
+			t.Errorf("%s: detected %s", test.Codec, got)
+		}
+
+		loaded, err := New(test.Data)
+		must(t, err)
+		if loaded.Codec != test.Codec {
+			t.Errorf("%s: codec was %s", test.Codec, loaded.Codec)
+		}
+		if !reflect.DeepEqual(store.Snapshot, loaded.Snapshot) {
+			t.Errorf("%s: snapshot was wrong: %#v", test.Codec, loaded.Snapshot)
+		}
+		if !reflect.DeepEqual(store.Log, loaded.Log) {
+			t.Errorf("%s: log was wrong: %#v", test.Codec, loaded.Log)
+		}
+
+		log, err := NewLog(test.Data)
+		must(t, err)
+		if !reflect.DeepEqual(store.Log, log) {
+			t.Errorf("%s: log was wrong: %#v", test.Codec, log)
+		}
+
+		loaded, err = NewCodec(test.Data, test.Codec)
+		must(t, err)
+		if loaded.Codec != test.Codec || !reflect.DeepEqual(store.Log, loaded.Log) {
+			t.Errorf("%s: known codec loaded wrong: %#v", test.Codec, loaded.Log)
+		}
+		log, err = NewLogCodec(test.Data, test.Codec)
+		must(t, err)
+		if !reflect.DeepEqual(store.Log, log) {
+			t.Errorf("%s: known codec log was wrong: %#v", test.Codec, log)
+		}
+	}
+
+	if _, err = NewCodec(jsonData, Codec(7)); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
 func TestMerge(t *testing.T) {
 	t.Parallel()
 
@@ -455,6 +519,79 @@ func TestMerge(t *testing.T) {
 			t.Errorf("merged differs: %#v", merged)
 		}
 	})
+	t.Run("ConflictsSetSet", func(t *testing.T) {
+		t.Parallel()
+
+		logA := []Tx{
+			{Time: 1, Kind: TxAdd, UUID: "1"},
+			{Time: 2, Kind: TxSetKey, UUID: "1", Key: "a", Value: "b"},
+			{Time: 4, Kind: TxSetKey, UUID: "1", Key: "a", Value: "local"},
+			{Time: 5, Kind: TxSetKey, UUID: "1", Key: "c", Value: "same"},
+		}
+		logB := []Tx{
+			{Time: 1, Kind: TxAdd, UUID: "1"},
+			{Time: 2, Kind: TxSetKey, UUID: "1", Key: "a", Value: "b"},
+			{Time: 3, Kind: TxSetKey, UUID: "1", Key: "a", Value: "x"},
+			{Time: 6, Kind: TxSetKey, UUID: "1", Key: "a", Value: "remote"},
+			{Time: 7, Kind: TxSetKey, UUID: "1", Key: "c", Value: "same"},
+		}
+
+		merged, conflicts := Merge(logA, logB, nil)
+		if len(merged) != 0 {
+			t.Error("merged should not be returned")
+		}
+		if len(conflicts) != 1 {
+			t.Fatal("there was", len(conflicts), "conflicts")
+		}
+		if conflicts[0].Kind != ConflictKindSetSet {
+			t.Error("conflict kind was wrong")
+		}
+		if conflicts[0].Initial.Value != "local" {
+			t.Error("local set was wrong")
+		}
+		if conflicts[0].Conflict.Value != "remote" {
+			t.Error("remote set was wrong")
+		}
+
+		keepRemote := make([]Conflict, len(conflicts))
+		keepLocal := make([]Conflict, len(conflicts))
+		copy(keepRemote, conflicts)
+		copy(keepLocal, conflicts)
+
+		// Remote is already last, nothing needs to be added
+		keepRemote[0].DiscardInitial()
+		merged, conflicts = Merge(logA, logB, keepRemote)
+		if len(conflicts) != 0 {
+			t.Errorf("conflicts should be empty: %#v", conflicts)
+		}
+		if len(merged) != 7 || merged[5].Value != "remote" {
+			t.Errorf("merged was wrong: %#v", merged)
+		}
+
+		keepLocal[0].DiscardConflict()
+		merged, conflicts = Merge(logA, logB, keepLocal)
+		if len(conflicts) != 0 {
+			t.Errorf("conflicts should be empty: %#v", conflicts)
+		}
+		if len(merged) != 8 {
+			t.Fatalf("merged was wrong: %#v", merged)
+		}
+		last := merged[7]
+		if last.Key != "a" || last.Value != "local" {
+			t.Errorf("the local value should have been set again: %#v", last)
+		}
+		if txID(last) <= txID(merged[6]) {
+			t.Error("the resolution should sort last:", txID(last))
+		}
+
+		// Merging the resolved log with either side is not a conflict again
+		if _, conflicts = Merge(merged, logB, nil); len(conflicts) != 0 {
+			t.Errorf("conflicts should be empty: %#v", conflicts)
+		}
+		if _, conflicts = Merge(logA, merged, nil); len(conflicts) != 0 {
+			t.Errorf("conflicts should be empty: %#v", conflicts)
+		}
+	})
 }
 
 func TestTransactions(t *testing.T) {
@@ -491,7 +628,42 @@ func TestTransactions(t *testing.T) {
 	if len(store.Log) != 3 {
 		t.Error("should have 3 txs")
 	}
-	if store.txPoint != 0 {
+	if store.InTransaction() {
+		t.Error("transaction should be ended")
+	}
+}
+
+func TestTransactionsNested(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	store.Begin()
+
+	uuid, err := store.Add()
+	must(t, err)
+
+	store.Begin()
+	store.Set(uuid, "test1", "value")
+	store.Rollback()
+	if len(store.Log) != 1 {
+		t.Error("should have 1 tx")
+	}
+
+	err = store.Do(func() error {
+		store.Set(uuid, "test2", "value")
+		return nil
+	})
+	must(t, err)
+	if len(store.Log) != 2 || !store.InTransaction() {
+		t.Error("should have 2 txs and still be in the transaction")
+	}
+
+	// The outer rollback undoes the committed savepoint as well
+	store.Rollback()
+	if len(store.Log) != 0 {
+		t.Error("should have 0 txs")
+	}
+	if store.InTransaction() {
 		t.Error("transaction should be ended")
 	}
 }
@@ -0,0 +1,59 @@
+package txlogs
+
+import "time"
+
+// Value is a value a key has had and the last time it was set to it. User
+// is empty if the change was not made in a multi-user file or the value came
+// from a checkpoint.
+type Value struct {
+	Value string
+	User  string
+	Time  time.Time
+}
+
+// Values returns each distinct value a key of an entry has had, most
+// recently set first. Values that were purged are left out and values that
+// were set more than once are only returned for the last time. The first
+// value is the current one unless the key has been deleted since.
+func (s *DB) Values(uuid, key string) ([]Value, error) {
+	txs := s.entryTxs(uuid)
+	if len(txs) == 0 {
+		return nil, UUIDNotFound(uuid)
+	}
+
+	// Log order, oldest first, so setting a value again moves it to the end
+	var values []Value
+	set := func(value Value) {
+		for i, v := range values {
+			if v.Value == value.Value {
+				values = append(values[:i], values[i+1:]...)
+				break
+			}
+		}
+		values = append(values, value)
+	}
+
+	for _, i := range txs {
+		tx := s.Log[i]
+		switch tx.Kind {
+		case TxSetKey:
+			if tx.Key != key || len(tx.Purged) != 0 {
+				continue
+			}
+			set(Value{Value: tx.Value, User: tx.User, Time: time.Unix(0, tx.Time)})
+		case TxCheckpoint:
+			snap, err := checkpointSnapshot(tx)
+			if err != nil {
+				return nil, err
+			}
+			if value, ok := snap[uuid][key]; ok {
+				set(Value{Value: value, Time: time.Unix(0, tx.Time)})
+			}
+		}
+	}
+
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, nil
+}
@@ -0,0 +1,78 @@
+package txlogs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValues(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{User: "alice"}
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "pass", "one")
+	store.Set(uuid, "user", "bob")
+	store.Set(uuid, "pass", "two")
+
+	store.User = "bob"
+	store.Set(uuid, "pass", "one")
+	store.Set(uuid, "pass", "three")
+
+	values, err := store.Values(uuid, "pass")
+	must(t, err)
+
+	want := []string{"three", "one", "two"}
+	if len(values) != len(want) {
+		t.Fatalf("wrong number of values: %#v", values)
+	}
+	for i, w := range want {
+		if values[i].Value != w {
+			t.Errorf("%d) value was wrong: %q", i, values[i].Value)
+		}
+	}
+	if v := values[1]; v.User != "bob" || !v.Time.Equal(time.Unix(0, store.Log[4].Time)) {
+		t.Errorf("one should be from the last time it was set: %#v", v)
+	}
+	if v := values[2]; v.User != "alice" {
+		t.Errorf("two was set by alice: %#v", v)
+	}
+
+	_, err = store.Purge(uuid, "pass")
+	must(t, err)
+	values, err = store.Values(uuid, "pass")
+	must(t, err)
+	if len(values) != 1 || values[0].Value != "three" {
+		t.Errorf("purged values should be left out: %#v", values)
+	}
+
+	if values, err = store.Values(uuid, "nope"); err != nil || len(values) != 0 {
+		t.Errorf("a key that was never set has no values: %#v %v", values, err)
+	}
+	if _, err = store.Values("nope", "pass"); !IsUUIDNotFound(err) {
+		t.Error("expected uuid not found:", err)
+	}
+}
+
+func TestValuesCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	store := new(DB)
+	uuid, err := store.Add()
+	must(t, err)
+	store.Set(uuid, "pass", "one")
+	store.Set(uuid, "pass", "two")
+
+	_, err = store.Compact(time.Now())
+	must(t, err)
+	store.Set(uuid, "pass", "three")
+
+	values, err := store.Values(uuid, "pass")
+	must(t, err)
+	if len(values) != 2 || values[0].Value != "three" || values[1].Value != "two" {
+		t.Errorf("values were wrong: %#v", values)
+	}
+	if values[1].User != "" {
+		t.Error("checkpoints have no user:", values[1].User)
+	}
+}
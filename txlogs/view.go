@@ -0,0 +1,112 @@
+package txlogs
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// View is the data as it was at a point in history. It's immutable, the
+// snapshot is built once when the view is created and every query after
+// that is answered from it.
+type View struct {
+	log      []Tx
+	snapshot map[string]Entry
+}
+
+// At returns a view of the data as it was at t, it includes every
+// transaction that happened at or before t.
+func (s *DB) At(t time.Time) (*View, error) {
+	unix := t.UnixNano()
+
+	// The log is in id order and ids start with the wall clock so this
+	// finds the first transaction after t
+	n := sort.Search(len(s.Log), func(i int) bool {
+		wall, _, ok := parseID(txID(s.Log[i]))
+		if !ok {
+			wall = s.Log[i].Time
+		}
+		return wall > unix
+	})
+
+	return s.AtVersion(n)
+}
+
+// AtVersion returns a view of the data after the first n transactions
+func (s *DB) AtVersion(n int) (*View, error) {
+	if n < 0 || n > len(s.Log) {
+		return nil, errors.New("there are not that many versions")
+	}
+
+	snap, err := s.replay(n)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &View{
+		log:      make([]Tx, n),
+		snapshot: snap,
+	}
+	copy(v.log, s.Log)
+
+	return v, nil
+}
+
+// Version is the number of transactions in the view
+func (v *View) Version() int {
+	return len(v.log)
+}
+
+// Time is when the last transaction in the view happened, the zero value if
+// there are none.
+func (v *View) Time() time.Time {
+	if len(v.log) == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, v.log[len(v.log)-1].Time)
+}
+
+// UUIDs of the entries that existed
+func (v *View) UUIDs() []string {
+	uuids := make([]string, 0, len(v.snapshot))
+	for uuid := range v.snapshot {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	return uuids
+}
+
+// Entry returns a copy of the entry, nil if it didn't exist
+func (v *View) Entry(uuid string) Entry {
+	entry, ok := v.snapshot[uuid]
+	if !ok {
+		return nil
+	}
+	return copyEntry(entry)
+}
+
+// DB returns a new database holding the view's log and snapshot so it can
+// be queried like any other (for example by wrapping it in a
+// blobformat.Blobs). Nothing done to it changes the view or the database
+// the view came from.
+func (v *View) DB() *DB {
+	db := &DB{
+		Version:  uint(len(v.log)),
+		Snapshot: make(map[string]Entry, len(v.snapshot)),
+		Log:      make([]Tx, len(v.log)),
+	}
+	copy(db.Log, v.log)
+	for uuid, entry := range v.snapshot {
+		db.Snapshot[uuid] = copyEntry(entry)
+	}
+
+	return db
+}
+
+func copyEntry(entry Entry) Entry {
+	cpy := make(Entry, len(entry))
+	for k, v := range entry {
+		cpy[k] = v
+	}
+	return cpy
+}
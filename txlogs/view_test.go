@@ -0,0 +1,53 @@
+package txlogs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestView(t *testing.T) {
+	t.Parallel()
+
+	store := &DB{Log: compactLog()}
+	migrateIDs(store.Log)
+
+	view, err := store.At(time.Unix(0, 4))
+	must(t, err)
+
+	if view.Version() != 4 {
+		t.Error("version was wrong:", view.Version())
+	}
+	if !view.Time().Equal(time.Unix(0, 4)) {
+		t.Error("time was wrong:", view.Time())
+	}
+	if uuids := view.UUIDs(); len(uuids) != 2 {
+		t.Error("should have 2 entries:", uuids)
+	}
+	if got := view.Entry("1")["a"]; got != "c" {
+		t.Error("value was wrong:", got)
+	}
+
+	// Changing what's returned doesn't change the view
+	view.Entry("1")["a"] = "changed"
+	db := view.DB()
+	db.Set("1", "a", "changed")
+	db.Log[0].UUID = "changed"
+	must(t, db.UpdateSnapshot())
+
+	if got := view.Entry("1")["a"]; got != "c" {
+		t.Error("view was changed:", got)
+	}
+	if store.Log[0].UUID != "1" || len(store.Log) != 6 {
+		t.Error("store was changed")
+	}
+
+	view, err = store.AtVersion(0)
+	must(t, err)
+	if len(view.UUIDs()) != 0 || !view.Time().IsZero() {
+		t.Error("view should be empty")
+	}
+
+	if _, err = store.AtVersion(7); err == nil {
+		t.Error("expected an error")
+	}
+}
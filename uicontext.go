@@ -8,6 +8,7 @@ import (
 
 	"github.com/aarondl/bpass/blobformat"
 	"github.com/aarondl/bpass/crypt"
+	"github.com/aarondl/bpass/txlogs"
 )
 
 type uiContext struct {
@@ -18,13 +19,22 @@ type uiContext struct {
 
 	created  bool
 	readOnly bool
-	startTx  int
+	// changed is set when the log is different from when it was loaded
+	changed bool
+	// version and compression are the crypt version and compression the
+	// file was loaded with, 0 for new files
+	version     int
+	compression int
 
 	filename      string
 	shortFilename string
 
 	// Decrypted and decoded storage
 	store blobformat.Blobs
+	// present is the real store while store holds a view of the past
+	present *txlogs.DB
+	// what repl commands can be undone and redone
+	undo, redo []undoUnit
 
 	// save user & password for syncing later
 	user string
@@ -39,8 +49,10 @@ type uiContext struct {
 func (u *uiContext) makeParams() (*crypt.Params, error) {
 	if len(u.master) == 0 {
 		return &crypt.Params{
-			Keys:  [][]byte{u.key},
-			Salts: [][]byte{u.salt},
+			Keys:        [][]byte{u.key},
+			Salts:       [][]byte{u.salt},
+			Encoding:    int(u.store.Codec),
+			Compression: u.saveCompression(),
 		}, nil
 	}
 
@@ -94,6 +106,82 @@ func (u *uiContext) makeParams() (*crypt.Params, error) {
 
 	p.IVM = u.ivm
 	p.Master = u.master
+	p.Encoding = int(u.store.Codec)
+	p.Compression = u.saveCompression()
 
 	return &p, nil
 }
+
+// saveCompression is how the file is compressed when it's saved, the way it
+// was loaded unless a flag says otherwise
+func (u *uiContext) saveCompression() int {
+	switch {
+	case flagNoCompress:
+		return crypt.CompressionNone
+	case flagCompress:
+		return crypt.CompressionDeflate
+	default:
+		return u.compression
+	}
+}
+
+// saveVersion is the crypt version the file is saved with. Files keep the
+// version they were loaded with so that older copies of bpass can still open
+// them, unless what's saved needs a newer one: version 2 to record a codec
+// other than json or a log older copies would damage (see legacyLog) and
+// version 3 to record compression. --no-compress saves version 3 files as
+// version 2.
+func (u *uiContext) saveVersion() int {
+	version := u.version
+	if version == 0 {
+		version = 1
+	}
+	if flagNoCompress && version > 2 {
+		version = 2
+	}
+	if version < 2 && (u.store.Codec != txlogs.CodecJSON || !legacyLog(u.store.Log)) {
+		version = 2
+	}
+	if u.saveCompression() != crypt.CompressionNone && version < 3 {
+		version = 3
+	}
+	return version
+}
+
+// legacyLog is true if copies of bpass that only know crypt version 1 can
+// save the log without losing anything. They drop the ids, links and users
+// of transactions and ignore kinds they don't know, which loses data and
+// makes the log impossible to merge with other copies.
+func legacyLog(log []txlogs.Tx) bool {
+	for _, tx := range log {
+		switch tx.Kind {
+		case txlogs.TxAdd, txlogs.TxDelete, txlogs.TxSetKey, txlogs.TxDeleteKey:
+		default:
+			return false
+		}
+		if len(tx.ID) != 0 || len(tx.Prev) != 0 || len(tx.User) != 0 ||
+			len(tx.Index) != 0 || len(tx.Purged) != 0 ||
+			(tx.Kind == txlogs.TxAdd && len(tx.Value) != 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileCodec returns the codec a decrypted file was encoded with. Version 1
+// files have nowhere to record it so the plaintext is sniffed.
+func fileCodec(params crypt.Params, pt []byte) txlogs.Codec {
+	if params.Version() == 1 {
+		return txlogs.DetectCodec(pt)
+	}
+	return txlogs.Codec(params.Encoding)
+}
+
+// watchChanges keeps changed up to date as db is edited. Undoing back to how
+// the log was when it was loaded isn't a change.
+func (u *uiContext) watchChanges(db *txlogs.DB) {
+	loaded := lastID(db.Log)
+	db.Subscribe(func(txlogs.Change) {
+		u.changed = lastID(db.Log) != loaded
+	})
+}
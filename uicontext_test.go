@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aarondl/bpass/blobformat"
+	"github.com/aarondl/bpass/crypt"
+	"github.com/aarondl/bpass/txlogs"
+)
+
+func TestSaveVersion(t *testing.T) {
+	// Not parallel, it changes the flags
+	defer func() { flagCompress, flagNoCompress = false, false }()
+
+	tests := []struct {
+		Version     int
+		Compression int
+		Codec       txlogs.Codec
+		Log         []txlogs.Tx
+		Compress    bool
+		NoCompress  bool
+		Want        int
+	}{
+		{Want: 1},
+		{Version: 1, Want: 1},
+		{Version: 2, Want: 2},
+		{Version: 3, Want: 3},
+		{Version: 1, Codec: txlogs.CodecMsgpack, Want: 2},
+		{Version: 3, Compression: crypt.CompressionDeflate, Want: 3},
+		{Version: 1, Compress: true, Want: 3},
+		{Version: 3, Compression: crypt.CompressionDeflate, NoCompress: true, Want: 2},
+		{Version: 1, NoCompress: true, Want: 1},
+		{Version: 1, Log: []txlogs.Tx{{Kind: txlogs.TxAdd, UUID: "a"}}, Want: 1},
+		{Version: 1, Log: []txlogs.Tx{{ID: "1", Kind: txlogs.TxAdd, UUID: "a"}}, Want: 2},
+		{Version: 1, Log: []txlogs.Tx{{Kind: txlogs.TxAddItem, UUID: "a", Key: "labels"}}, Want: 2},
+		{Version: 3, Log: []txlogs.Tx{{ID: "1", Kind: txlogs.TxAdd, UUID: "a"}}, NoCompress: true, Want: 2},
+	}
+
+	for i, test := range tests {
+		flagCompress, flagNoCompress = test.Compress, test.NoCompress
+		u := uiContext{
+			version:     test.Version,
+			compression: test.Compression,
+			store:       blobformat.Blobs{DB: &txlogs.DB{Codec: test.Codec, Log: test.Log}},
+		}
+
+		if got := u.saveVersion(); got != test.Want {
+			t.Errorf("%d) version was %d, want %d", i, got, test.Want)
+		}
+	}
+}
+
+func TestWatchChanges(t *testing.T) {
+	t.Parallel()
+
+	db := new(txlogs.DB)
+	uuid, err := db.Add()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var u uiContext
+	u.watchChanges(db)
+
+	db.Set(uuid, "a", "b")
+	db.Set(uuid, "c", "d")
+	if !u.changed {
+		t.Error("it should be changed")
+	}
+	if err = db.RollbackN(1); err != nil {
+		t.Fatal(err)
+	}
+	if !u.changed {
+		t.Error("it should still be changed")
+	}
+	if err = db.RollbackN(1); err != nil {
+		t.Fatal(err)
+	}
+	if u.changed {
+		t.Error("undoing back to how it was loaded is not a change")
+	}
+}
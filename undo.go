@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aarondl/bpass/txlogs"
+)
+
+// undoKind is how running a repl command affects undo and redo
+type undoKind int
+
+const (
+	// undoRecord commands are recorded so they can be undone as one unit
+	undoRecord undoKind = iota
+	// undoClear commands change more than what's in the log or rewrite it
+	// so they can't be undone, running them forgets what could be undone
+	undoClear
+	// undoSelf commands are undo and redo, they manage the stacks themselves
+	undoSelf
+)
+
+// undoUnit is the transactions made by a single repl command
+type undoUnit struct {
+	line string
+	txs  []txlogs.Tx
+}
+
+// record runs a command inside a transaction, if it added to the log what
+// it added is pushed onto the undo stack and the redo stack is forgotten.
+func (u *uiContext) record(line string, fn func() error) error {
+	db := u.store.DB
+	before := len(db.Log)
+	head := lastID(db.Log)
+
+	db.Begin()
+	err := fn()
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+	db.Commit()
+
+	if lastID(db.Log) == head || len(db.Log) < before {
+		return nil
+	}
+
+	txs := make([]txlogs.Tx, len(db.Log)-before)
+	copy(txs, db.Log[before:])
+	u.undo = append(u.undo, undoUnit{line: line, txs: txs})
+	u.redo = nil
+	return nil
+}
+
+// forgetUndo clears the undo and redo stacks
+func (u *uiContext) forgetUndo() {
+	u.undo = nil
+	u.redo = nil
+}
+
+// undoN undoes the last n commands
+func (u *uiContext) undoN(n int) error {
+	if len(u.undo) == 0 {
+		infoColor.Println("nothing to undo")
+		return nil
+	}
+
+	for ; n > 0 && len(u.undo) != 0; n-- {
+		unit := u.undo[len(u.undo)-1]
+		log := u.store.DB.Log
+
+		// Make sure nothing has changed the log out from under us
+		if len(log) < len(unit.txs) || lastID(log) != lastID(unit.txs) {
+			u.forgetUndo()
+			errColor.Println("the history has changed, cannot undo")
+			return nil
+		}
+
+		if err := u.store.RollbackN(uint(len(unit.txs))); err != nil {
+			return err
+		}
+
+		u.undo = u.undo[:len(u.undo)-1]
+		u.redo = append(u.redo, unit)
+		fmt.Fprintln(u.out, "undid:", unit.line)
+	}
+
+	return u.store.UpdateSnapshot()
+}
+
+// redoN redoes the last n commands that were undone
+func (u *uiContext) redoN(n int) error {
+	if len(u.redo) == 0 {
+		infoColor.Println("nothing to redo")
+		return nil
+	}
+
+	for ; n > 0 && len(u.redo) != 0; n-- {
+		unit := u.redo[len(u.redo)-1]
+
+		// The transactions are put back as they were, they were undone from
+		// the end of the log so they still follow on from it
+		u.store.Append(unit.txs...)
+
+		u.redo = u.redo[:len(u.redo)-1]
+		u.undo = append(u.undo, unit)
+		fmt.Fprintln(u.out, "redid:", unit.line)
+	}
+
+	return u.store.UpdateSnapshot()
+}
+
+func lastID(log []txlogs.Tx) string {
+	if len(log) == 0 {
+		return ""
+	}
+	return log[len(log)-1].ID
+}